@@ -1,17 +1,35 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/youngprinnce/go-ecom/controller/apikey"
+	"github.com/youngprinnce/go-ecom/controller/attribute"
+	"github.com/youngprinnce/go-ecom/controller/audit"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/controller/download"
 	"github.com/youngprinnce/go-ecom/controller/order"
 	"github.com/youngprinnce/go-ecom/controller/product"
+	"github.com/youngprinnce/go-ecom/controller/recommendation"
+	"github.com/youngprinnce/go-ecom/controller/review"
+	"github.com/youngprinnce/go-ecom/controller/role"
+	"github.com/youngprinnce/go-ecom/controller/subscription"
+	"github.com/youngprinnce/go-ecom/controller/throttle"
 	"github.com/youngprinnce/go-ecom/controller/user"
+	"github.com/youngprinnce/go-ecom/controller/wishlist"
+	"github.com/youngprinnce/go-ecom/config"
 	"github.com/youngprinnce/go-ecom/docs"
+	"github.com/youngprinnce/go-ecom/jobs"
 	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
 )
 
 type APIServer struct {
@@ -29,6 +47,16 @@ func NewAPIServer(addr string, db *sql.DB) *APIServer {
 func (s *APIServer) Run() error {
 	router := gin.Default()
 
+	// Only take the client IP from X-Forwarded-For when the request came through a known
+	// proxy, so clients can't dodge per-IP login limits by setting the header themselves
+	var trustedProxies []string
+	if config.Envs.TRUSTED_PROXIES != "" {
+		trustedProxies = strings.Split(config.Envs.TRUSTED_PROXIES, ",")
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return err
+	}
+
 	docs.SwaggerInfo.BasePath = "/api/v1"
 	docs.SwaggerInfo.Schemes = []string{"http"}
 	docs.SwaggerInfo.Host = "localhost:8080"
@@ -41,18 +69,126 @@ func (s *APIServer) Run() error {
 
 	api := router.Group("/api/v1")
 
+	// Sign access tokens with the keys in JWT_KEYS_DIR instead of JWT_SECRET when it is set
+	if config.Envs.JWT_KEYS_DIR != "" {
+		keys, err := auth.LoadKeySet(config.Envs.JWT_KEYS_DIR, config.Envs.JWT_SIGNING_KEY_ID)
+		if err != nil {
+			return err
+		}
+		auth.SetKeySet(keys)
+	}
+
+	// Account emails go out right away, verification and password reset emails and notifications
+	// about products are rate limited
+	var mailer notification.Sender = notification.ConsoleSender{}
+	if config.Envs.MAIL_DRIVER == "file" {
+		mailer = notification.NewFileSender(config.Envs.MAIL_DIR, config.Envs.MAIL_FROM)
+	}
+
 	userStore := user.NewStore(s.db)
-	userHandler := user.NewHandler(userStore)
+	verificationMailer := notification.NewRateLimitedSender(
+		mailer,
+		int(config.Envs.EMAIL_VERIFICATION_RESEND_LIMIT),
+		time.Duration(config.Envs.EMAIL_VERIFICATION_RESEND_WINDOW_SECONDS)*time.Second,
+	)
+	resetMailer := notification.NewRateLimitedSender(
+		mailer,
+		int(config.Envs.PASSWORD_RESET_REQUEST_LIMIT),
+		time.Duration(config.Envs.PASSWORD_RESET_REQUEST_WINDOW_SECONDS)*time.Second,
+	)
+	// Failed logins are tracked in MySQL unless LOGIN_ATTEMPT_STORE is "memory"
+	var loginAttempts types.LoginAttemptStore = throttle.NewStore(s.db)
+	if config.Envs.LOGIN_ATTEMPT_STORE == "memory" {
+		loginAttempts = throttle.NewMemoryStore()
+	}
+	auditStore := audit.NewStore(s.db)
+	auditHandler := audit.NewHandler(auditStore)
+	auditHandler.RegisterRoutes(api)
+
+	// Staff routes require the permissions granted to roles
+	roleStore := role.NewStore(s.db)
+	roleHandler := role.NewHandler(roleStore, auditStore)
+	roleHandler.RegisterRoutes(api)
+	middleware.SetPermissionStore(roleStore)
+
+	// Integrations call staff routes with scoped API keys instead of logging in
+	apiKeyStore := apikey.NewStore(s.db)
+	apiKeyHandler := apikey.NewHandler(apiKeyStore, roleStore, auditStore)
+	apiKeyHandler.RegisterRoutes(api)
+	middleware.SetAPIKeyStore(apiKeyStore)
+
+	userHandler := user.NewHandler(userStore, roleStore, loginAttempts, auditStore, mailer, verificationMailer, resetMailer)
 	userHandler.RegisterRoutes(api)
+	userHandler.RegisterWellKnownRoutes(router)
+
+	// Reject tokens of sessions that have been logged out or revoked
+	middleware.SetSessionStore(userStore)
+
+	// Customers are told when products on their wishlists, or that they subscribed to,
+	// come back in stock
+	sender := notification.NewRateLimitedSender(
+		mailer,
+		int(config.Envs.NOTIFICATION_RATE_LIMIT),
+		time.Duration(config.Envs.NOTIFICATION_RATE_WINDOW_SECONDS)*time.Second,
+	)
+	wishlistStore := wishlist.NewStore(s.db)
+	subscriptionStore := subscription.NewStore(s.db)
+	restockNotifier := types.RestockNotifiers{
+		subscription.NewNotifier(subscriptionStore, sender),
+		wishlist.NewNotifier(wishlistStore, sender),
+	}
 
 	productStore := product.NewStore(s.db)
-	productHandler := product.NewHandler(productStore)
+	productHandler := product.NewHandler(productStore, restockNotifier)
 	productHandler.RegisterRoutes(api)
 
 	orderStore := order.NewStore(s.db)
-	orderHandler := order.NewHandler(productStore, orderStore, userStore)
+	orderHandler := order.NewHandler(productStore, orderStore, userStore, restockNotifier)
 	orderHandler.RegisterRoutes(api)
 
+	reviewStore := review.NewStore(s.db)
+	reviewHandler := review.NewHandler(reviewStore, productStore)
+	reviewHandler.RegisterRoutes(api)
+
+	downloadStore := download.NewStore(s.db)
+	downloadHandler := download.NewHandler(downloadStore, productStore, orderStore)
+	downloadHandler.RegisterRoutes(api)
+
+	attributeStore := attribute.NewStore(s.db)
+	attributeHandler := attribute.NewHandler(attributeStore, productStore)
+	attributeHandler.RegisterRoutes(api)
+
+	recommendationStore := recommendation.NewStore(s.db)
+	recommendationHandler := recommendation.NewHandler(recommendationStore, productStore)
+	recommendationHandler.RegisterRoutes(api)
+
+	wishlistHandler := wishlist.NewHandler(wishlistStore, productStore)
+	wishlistHandler.RegisterRoutes(api)
+
+	subscriptionHandler := subscription.NewHandler(subscriptionStore, productStore)
+	subscriptionHandler.RegisterRoutes(api)
+
+	// Background jobs
+	jobs.Start(context.Background(),
+		jobs.Job{
+			Name:     "price-schedules",
+			Interval: time.Duration(config.Envs.PRICE_SCHEDULER_INTERVAL_SECONDS) * time.Second,
+			Run:      productStore.ApplyDuePriceSchedules,
+		},
+		jobs.Job{
+			Name:     "product-publisher",
+			Interval: time.Duration(config.Envs.PUBLISHER_INTERVAL_SECONDS) * time.Second,
+			Run:      productStore.PublishDueProducts,
+		},
+		jobs.Job{
+			Name:     "recommendations",
+			Interval: time.Duration(config.Envs.RECOMMENDATIONS_INTERVAL_SECONDS) * time.Second,
+			Run: func() (int, error) {
+				return recommendationStore.ComputeRecommendations(int(config.Envs.RECOMMENDATIONS_MIN_ORDERS))
+			},
+		},
+	)
+
 	// Swagger route
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/product"
+	"github.com/youngprinnce/go-ecom/controller/product/catalogfile"
+	"github.com/youngprinnce/go-ecom/db"
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+const usage = `usage:
+  catalog import [-format csv|json] [-dry-run] <file>
+  catalog export [-format csv|json] [-o file]`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	cfg := mysqlDriver.Config{
+		User:                 config.Envs.DB.User,
+		Passwd:               config.Envs.DB.Passwd,
+		Net:                  config.Envs.DB.Net,
+		Addr:                 config.Envs.DB.Addr,
+		DBName:               config.Envs.DB.DBName,
+		AllowNativePasswords: config.Envs.DB.AllowNativePasswords,
+		ParseTime:            config.Envs.DB.ParseTime,
+	}
+
+	db, err := db.NewMySQLStorage(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	store := product.NewStore(db)
+
+	switch os.Args[1] {
+	case "import":
+		err = runImport(store, os.Args[2:])
+	case "export":
+		err = runExport(store, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", os.Args[1], usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runImport upserts the products in a CSV or JSON file and prints the import report.
+func runImport(store *product.Store, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "file format (csv or json), detected from the file extension when omitted")
+	dryRun := flags.Bool("dry-run", false, "validate and report without writing")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rows []types.ProductImportRow
+	var rowErrors []types.ProductImportRowError
+	switch *format {
+	case "csv":
+		rows, rowErrors, err = catalogfile.ParseCSV(file)
+	case "json":
+		rows, err = catalogfile.ParseJSON(file)
+	default:
+		err = fmt.Errorf("unsupported format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := catalogfile.Import(store, rows, rowErrors, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d of %d rows failed", len(report.Errors), report.Total)
+	}
+	return nil
+}
+
+// runExport writes every product that has not been archived to a file or stdout.
+func runExport(store *product.Store, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "csv", "file format (csv or json)")
+	output := flags.String("o", "", "output file, stdout when omitted")
+	flags.Parse(args)
+
+	products, err := store.GetProducts()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *format {
+	case "csv":
+		return catalogfile.ExportCSV(w, products)
+	case "json":
+		return catalogfile.ExportJSON(w, products)
+	default:
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+}
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reviews (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  productId INT UNSIGNED NOT NULL,
+  userId INT UNSIGNED NOT NULL,
+  rating TINYINT UNSIGNED NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  body TEXT NOT NULL,
+  status ENUM('pending', 'approved', 'hidden') NOT NULL DEFAULT 'pending',
+  helpfulCount INT UNSIGNED NOT NULL DEFAULT 0,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY (productId, userId),
+  FOREIGN KEY (productId) REFERENCES products(id),
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS review_votes;
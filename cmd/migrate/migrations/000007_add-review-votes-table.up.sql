@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS review_votes (
+  reviewId INT UNSIGNED NOT NULL,
+  userId INT UNSIGNED NOT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (reviewId, userId),
+  FOREIGN KEY (reviewId) REFERENCES reviews(id) ON DELETE CASCADE,
+  FOREIGN KEY (userId) REFERENCES users(id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE products
+  DROP COLUMN ratingAverage,
+  DROP COLUMN ratingCount;
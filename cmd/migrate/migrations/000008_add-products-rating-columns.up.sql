@@ -0,0 +1,3 @@
+ALTER TABLE products
+  ADD COLUMN ratingAverage DECIMAL(3, 2) NOT NULL DEFAULT 0,
+  ADD COLUMN ratingCount INT UNSIGNED NOT NULL DEFAULT 0;
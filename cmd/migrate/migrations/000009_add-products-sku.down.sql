@@ -0,0 +1,3 @@
+ALTER TABLE products
+  DROP INDEX products_sku_unique,
+  DROP COLUMN sku;
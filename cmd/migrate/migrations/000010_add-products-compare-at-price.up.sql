@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN compareAtPrice DECIMAL(10, 2) NULL DEFAULT NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS product_price_schedules (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  productId INT UNSIGNED NOT NULL,
+  type ENUM('price', 'sale') NOT NULL,
+  price DECIMAL(10, 2) NOT NULL,
+  startsAt TIMESTAMP NOT NULL,
+  endsAt TIMESTAMP NULL DEFAULT NULL,
+  status ENUM('scheduled', 'active', 'completed', 'cancelled') NOT NULL DEFAULT 'scheduled',
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  INDEX (status, startsAt),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS product_price_history (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  productId INT UNSIGNED NOT NULL,
+  price DECIMAL(10, 2) NOT NULL,
+  compareAtPrice DECIMAL(10, 2) NULL DEFAULT NULL,
+  source VARCHAR(50) NOT NULL,
+  scheduleId INT UNSIGNED NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  INDEX (productId, createdAt),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE,
+  FOREIGN KEY (scheduleId) REFERENCES product_price_schedules(id) ON DELETE SET NULL
+);
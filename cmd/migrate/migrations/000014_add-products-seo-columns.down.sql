@@ -0,0 +1,5 @@
+ALTER TABLE products
+  DROP INDEX products_slug_unique,
+  DROP COLUMN slug,
+  DROP COLUMN metaTitle,
+  DROP COLUMN metaDescription;
@@ -0,0 +1,5 @@
+ALTER TABLE products
+  ADD COLUMN slug VARCHAR(255) NULL DEFAULT NULL,
+  ADD COLUMN metaTitle VARCHAR(255) NOT NULL DEFAULT '',
+  ADD COLUMN metaDescription VARCHAR(500) NOT NULL DEFAULT '',
+  ADD UNIQUE KEY products_slug_unique (slug);
@@ -0,0 +1,3 @@
+UPDATE products
+SET slug = CONCAT(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-')), '-', id)
+WHERE slug IS NULL;
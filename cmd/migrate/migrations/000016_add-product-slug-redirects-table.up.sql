@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS product_slug_redirects (
+  slug VARCHAR(255) NOT NULL,
+  productId INT UNSIGNED NOT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (slug),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+ALTER TABLE products
+  DROP INDEX products_status_publish_at,
+  DROP COLUMN status,
+  DROP COLUMN publishAt;
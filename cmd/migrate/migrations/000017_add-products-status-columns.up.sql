@@ -0,0 +1,4 @@
+ALTER TABLE products
+  ADD COLUMN status ENUM('draft', 'published', 'scheduled', 'unlisted') NOT NULL DEFAULT 'draft',
+  ADD COLUMN publishAt TIMESTAMP NULL DEFAULT NULL,
+  ADD INDEX products_status_publish_at (status, publishAt);
@@ -0,0 +1 @@
+UPDATE products SET status = 'draft', publishAt = NULL;
@@ -0,0 +1 @@
+UPDATE products SET status = 'published', publishAt = createdAt;
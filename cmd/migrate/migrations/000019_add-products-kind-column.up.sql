@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN kind ENUM('simple', 'bundle') NOT NULL DEFAULT 'simple';
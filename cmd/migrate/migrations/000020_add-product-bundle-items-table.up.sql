@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS product_bundle_items (
+  bundleId INT UNSIGNED NOT NULL,
+  componentId INT UNSIGNED NOT NULL,
+  quantity INT UNSIGNED NOT NULL,
+  PRIMARY KEY (bundleId, componentId),
+  FOREIGN KEY (bundleId) REFERENCES products(id) ON DELETE CASCADE,
+  FOREIGN KEY (componentId) REFERENCES products(id)
+);
@@ -0,0 +1 @@
+ALTER TABLE order_items ADD COLUMN components JSON NULL DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN isDigital BOOLEAN NOT NULL DEFAULT FALSE;
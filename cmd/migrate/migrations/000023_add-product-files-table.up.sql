@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS product_files (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  productId INT UNSIGNED NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  path VARCHAR(255) NOT NULL,
+  size BIGINT UNSIGNED NOT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE order_items ADD COLUMN downloadCount INT UNSIGNED NOT NULL DEFAULT 0;
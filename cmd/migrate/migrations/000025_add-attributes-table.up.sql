@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS attributes (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  code VARCHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  type ENUM('text', 'number', 'boolean') NOT NULL,
+  unit VARCHAR(32) NOT NULL DEFAULT '',
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY (code)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS product_attribute_values (
+  productId INT UNSIGNED NOT NULL,
+  attributeId INT UNSIGNED NOT NULL,
+  value VARCHAR(255) NOT NULL,
+  PRIMARY KEY (productId, attributeId),
+  KEY (attributeId, value),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE,
+  FOREIGN KEY (attributeId) REFERENCES attributes(id) ON DELETE CASCADE
+);
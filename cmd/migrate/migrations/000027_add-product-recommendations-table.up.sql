@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS product_recommendations (
+  productId INT UNSIGNED NOT NULL,
+  relatedProductId INT UNSIGNED NOT NULL,
+  orderCount INT UNSIGNED NOT NULL,
+  score DOUBLE NOT NULL,
+  updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (productId, relatedProductId),
+  KEY (productId, score),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE,
+  FOREIGN KEY (relatedProductId) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS wishlists (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  userId INT UNSIGNED NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  shareToken CHAR(32) NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY (userId, name),
+  UNIQUE KEY (shareToken),
+  FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS wishlist_items (
+  wishlistId INT UNSIGNED NOT NULL,
+  productId INT UNSIGNED NOT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (wishlistId, productId),
+  KEY (productId),
+  FOREIGN KEY (wishlistId) REFERENCES wishlists(id) ON DELETE CASCADE,
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE
+);
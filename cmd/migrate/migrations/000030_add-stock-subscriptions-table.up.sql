@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS stock_subscriptions (
+  productId INT UNSIGNED NOT NULL,
+  userId INT UNSIGNED NOT NULL,
+  notifiedAt TIMESTAMP NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (productId, userId),
+  KEY (userId),
+  FOREIGN KEY (productId) REFERENCES products(id) ON DELETE CASCADE,
+  FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
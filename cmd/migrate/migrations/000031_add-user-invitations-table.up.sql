@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_invitations (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  email VARCHAR(255) NOT NULL,
+  role ENUM('admin', 'user') NOT NULL DEFAULT 'admin',
+  invitedBy INT UNSIGNED NULL,
+  expiresAt TIMESTAMP NOT NULL,
+  acceptedAt TIMESTAMP NULL DEFAULT NULL,
+  revokedAt TIMESTAMP NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY (email),
+  FOREIGN KEY (invitedBy) REFERENCES users(id) ON DELETE SET NULL
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  userId INT UNSIGNED NOT NULL,
+  tokenHash CHAR(64) NOT NULL,
+  expiresAt TIMESTAMP NOT NULL,
+  usedAt TIMESTAMP NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY (tokenHash),
+  KEY (userId),
+  FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
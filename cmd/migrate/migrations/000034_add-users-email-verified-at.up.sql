@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN emailVerifiedAt TIMESTAMP NULL DEFAULT NULL AFTER role;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+  ADD COLUMN totpSecret VARCHAR(64) NULL DEFAULT NULL AFTER emailVerifiedAt,
+  ADD COLUMN totpEnabledAt TIMESTAMP NULL DEFAULT NULL AFTER totpSecret,
+  ADD COLUMN totpLastStep BIGINT NULL DEFAULT NULL AFTER totpEnabledAt;
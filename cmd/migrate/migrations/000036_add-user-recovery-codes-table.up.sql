@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  userId INT UNSIGNED NOT NULL,
+  codeHash CHAR(64) NOT NULL,
+  usedAt TIMESTAMP NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY (userId, codeHash),
+  FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
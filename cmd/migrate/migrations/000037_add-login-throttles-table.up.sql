@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS login_throttles (
+  throttleKey VARCHAR(320) NOT NULL,
+  failures INT UNSIGNED NOT NULL DEFAULT 0,
+  lastFailureAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  blockedUntil TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (throttleKey)
+);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN disabledAt TIMESTAMP NULL DEFAULT NULL AFTER totpLastStep;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  actorId INT UNSIGNED NULL,
+  action VARCHAR(64) NOT NULL,
+  targetType VARCHAR(64) NOT NULL,
+  targetId INT UNSIGNED NULL,
+  details JSON NULL,
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY (targetType, targetId),
+  KEY (actorId),
+  FOREIGN KEY (actorId) REFERENCES users(id) ON DELETE SET NULL
+);
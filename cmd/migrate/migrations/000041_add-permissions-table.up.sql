@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS permissions (
+  name VARCHAR(64) NOT NULL,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (name)
+);
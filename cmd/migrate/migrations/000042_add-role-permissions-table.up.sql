@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role VARCHAR(64) NOT NULL,
+  permission VARCHAR(64) NOT NULL,
+  PRIMARY KEY (role, permission),
+  KEY (permission),
+  FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+  FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
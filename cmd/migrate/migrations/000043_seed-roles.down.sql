@@ -0,0 +1 @@
+DELETE FROM roles WHERE name IN ('admin', 'user', 'support', 'inventory_manager', 'finance');
@@ -0,0 +1,6 @@
+INSERT INTO roles (name, description, builtIn) VALUES
+  ('admin', 'Full access to the store', TRUE),
+  ('user', 'Customer', TRUE),
+  ('support', 'Helps customers with their accounts, orders and reviews', FALSE),
+  ('inventory_manager', 'Manages the catalog', FALSE),
+  ('finance', 'Handles orders and payments', FALSE);
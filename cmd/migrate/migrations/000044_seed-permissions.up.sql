@@ -0,0 +1,11 @@
+INSERT INTO permissions (name, description) VALUES
+  ('products:read', 'View all products, including drafts and archived ones, and their prices, files and attributes'),
+  ('products:write', 'Create, update, publish, archive, import and price products, and manage their files and attributes'),
+  ('attributes:write', 'Define product attributes'),
+  ('orders:read', 'View the orders of any customer'),
+  ('orders:write', 'Change the status of orders'),
+  ('reviews:moderate', 'Approve and hide reviews'),
+  ('users:read', 'Search and view users'),
+  ('users:write', 'Disable, enable and unlock users, and force password resets'),
+  ('roles:manage', 'Manage roles, assign them to users and invite staff'),
+  ('audit:read', 'View the audit log');
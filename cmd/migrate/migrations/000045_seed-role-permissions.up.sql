@@ -0,0 +1,20 @@
+INSERT INTO role_permissions (role, permission) VALUES
+  ('admin', 'products:read'),
+  ('admin', 'products:write'),
+  ('admin', 'attributes:write'),
+  ('admin', 'orders:read'),
+  ('admin', 'orders:write'),
+  ('admin', 'reviews:moderate'),
+  ('admin', 'users:read'),
+  ('admin', 'users:write'),
+  ('admin', 'roles:manage'),
+  ('admin', 'audit:read'),
+  ('support', 'orders:read'),
+  ('support', 'reviews:moderate'),
+  ('support', 'users:read'),
+  ('support', 'users:write'),
+  ('inventory_manager', 'products:read'),
+  ('inventory_manager', 'products:write'),
+  ('inventory_manager', 'attributes:write'),
+  ('finance', 'orders:read'),
+  ('finance', 'orders:write');
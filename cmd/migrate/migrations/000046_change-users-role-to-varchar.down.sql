@@ -0,0 +1,3 @@
+ALTER TABLE users
+  DROP FOREIGN KEY users_role_fk,
+  MODIFY COLUMN role ENUM('admin', 'user') NOT NULL DEFAULT 'user';
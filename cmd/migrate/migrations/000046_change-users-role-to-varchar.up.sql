@@ -0,0 +1,3 @@
+ALTER TABLE users
+  MODIFY COLUMN role VARCHAR(64) NOT NULL DEFAULT 'user',
+  ADD CONSTRAINT users_role_fk FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
@@ -0,0 +1,3 @@
+ALTER TABLE user_invitations
+  DROP FOREIGN KEY user_invitations_role_fk,
+  MODIFY COLUMN role ENUM('admin', 'user') NOT NULL DEFAULT 'admin';
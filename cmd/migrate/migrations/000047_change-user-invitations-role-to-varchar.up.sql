@@ -0,0 +1,3 @@
+ALTER TABLE user_invitations
+  MODIFY COLUMN role VARCHAR(64) NOT NULL DEFAULT 'admin',
+  ADD CONSTRAINT user_invitations_role_fk FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE;
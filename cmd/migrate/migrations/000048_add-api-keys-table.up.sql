@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  name VARCHAR(255) NOT NULL,
+  prefix VARCHAR(16) NOT NULL,
+  keyHash CHAR(64) NOT NULL,
+  scopes JSON NOT NULL,
+  createdBy INT UNSIGNED NULL,
+  expiresAt TIMESTAMP NULL DEFAULT NULL,
+  lastUsedAt TIMESTAMP NULL DEFAULT NULL,
+  revokedAt TIMESTAMP NULL DEFAULT NULL,
+  createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY (prefix),
+  FOREIGN KEY (createdBy) REFERENCES users(id) ON DELETE SET NULL
+);
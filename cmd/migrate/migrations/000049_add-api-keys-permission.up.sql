@@ -0,0 +1 @@
+INSERT INTO permissions (name, description) VALUES ('api_keys:manage', 'Create, list and revoke API keys');
@@ -0,0 +1 @@
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'api_keys:manage');
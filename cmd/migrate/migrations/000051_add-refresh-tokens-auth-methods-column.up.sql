@@ -0,0 +1 @@
+ALTER TABLE refresh_tokens ADD COLUMN authMethods VARCHAR(32) NOT NULL DEFAULT 'pwd' AFTER tokenHash;
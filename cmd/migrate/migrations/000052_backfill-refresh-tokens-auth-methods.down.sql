@@ -0,0 +1 @@
+UPDATE refresh_tokens SET authMethods = 'pwd';
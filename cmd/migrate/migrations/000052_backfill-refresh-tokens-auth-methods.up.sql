@@ -0,0 +1,4 @@
+UPDATE refresh_tokens
+JOIN users ON users.id = refresh_tokens.userId
+SET refresh_tokens.authMethods = 'pwd,otp'
+WHERE users.totpEnabledAt IS NOT NULL;
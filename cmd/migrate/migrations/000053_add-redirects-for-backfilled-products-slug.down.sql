@@ -0,0 +1,4 @@
+DELETE product_slug_redirects
+FROM product_slug_redirects
+JOIN products p ON p.id = product_slug_redirects.productId
+WHERE product_slug_redirects.slug = p.slug;
@@ -0,0 +1,30 @@
+INSERT IGNORE INTO product_slug_redirects (slug, productId)
+SELECT p.slug, p.id
+FROM products p
+LEFT JOIN products taken ON taken.slug = CONCAT(
+  COALESCE(
+    NULLIF(TRIM(TRAILING '-' FROM LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(CONVERT(LOWER(p.name) USING utf8mb4) COLLATE utf8mb4_bin, '[^a-z0-9]+', '-')), 200)), ''),
+    'product'
+  ),
+  '-',
+  p.id
+) AND taken.id <> p.id
+LEFT JOIN product_slug_redirects redirected ON redirected.slug = CONCAT(
+  COALESCE(
+    NULLIF(TRIM(TRAILING '-' FROM LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(CONVERT(LOWER(p.name) USING utf8mb4) COLLATE utf8mb4_bin, '[^a-z0-9]+', '-')), 200)), ''),
+    'product'
+  ),
+  '-',
+  p.id
+) AND redirected.productId <> p.id
+WHERE p.slug = CONCAT(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(p.name), '[^a-z0-9]+', '-')), '-', p.id)
+  AND taken.id IS NULL
+  AND redirected.slug IS NULL
+  AND p.slug <> CONCAT(
+    COALESCE(
+      NULLIF(TRIM(TRAILING '-' FROM LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(CONVERT(LOWER(p.name) USING utf8mb4) COLLATE utf8mb4_bin, '[^a-z0-9]+', '-')), 200)), ''),
+      'product'
+    ),
+    '-',
+    p.id
+  );
@@ -0,0 +1,11 @@
+UPDATE products p
+JOIN product_slug_redirects redirected ON redirected.productId = p.id AND redirected.slug = CONCAT(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(p.name), '[^a-z0-9]+', '-')), '-', p.id)
+SET p.slug = redirected.slug
+WHERE p.slug = CONCAT(
+  COALESCE(
+    NULLIF(TRIM(TRAILING '-' FROM LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(CONVERT(LOWER(p.name) USING utf8mb4) COLLATE utf8mb4_bin, '[^a-z0-9]+', '-')), 200)), ''),
+    'product'
+  ),
+  '-',
+  p.id
+);
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"os"
 	"strconv"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/hkdf"
 )
 
 var Envs = initConfig()
@@ -14,6 +18,43 @@ type Config struct {
 	PORT string
 	JWT_EXPIRE_IN_SECONDS int64
 	JWT_SECRET string
+	JWT_KEYS_DIR string
+	JWT_SIGNING_KEY_ID string
+	JWT_ISSUER string
+	JWT_AUDIENCE string
+	REFRESH_TOKEN_TTL_SECONDS int64
+	PRICE_SCHEDULER_INTERVAL_SECONDS int64
+	PUBLISHER_INTERVAL_SECONDS int64
+	DIGITAL_FILES_DIR string
+	DOWNLOAD_SECRET string
+	DOWNLOAD_LINK_TTL_SECONDS int64
+	MAX_DOWNLOADS_PER_ITEM int64
+	RECOMMENDATIONS_INTERVAL_SECONDS int64
+	RECOMMENDATIONS_MIN_ORDERS int64
+	NOTIFICATION_RATE_LIMIT int64
+	NOTIFICATION_RATE_WINDOW_SECONDS int64
+	INVITATION_TTL_SECONDS int64
+	PASSWORD_RESET_TTL_SECONDS int64
+	PASSWORD_RESET_REQUEST_LIMIT int64
+	PASSWORD_RESET_REQUEST_WINDOW_SECONDS int64
+	MAIL_DRIVER string
+	MAIL_DIR string
+	MAIL_FROM string
+	APP_URL string
+	EMAIL_VERIFICATION_TTL_SECONDS int64
+	EMAIL_VERIFICATION_RESEND_LIMIT int64
+	EMAIL_VERIFICATION_RESEND_WINDOW_SECONDS int64
+	REQUIRE_VERIFIED_EMAIL_FOR_CHECKOUT bool
+	TOTP_ISSUER string
+	MFA_TOKEN_TTL_SECONDS int64
+	REQUIRE_2FA_FOR_ADMINS bool
+	LOGIN_ATTEMPT_STORE string
+	LOGIN_MAX_FAILURES_PER_ACCOUNT int64
+	LOGIN_MAX_FAILURES_PER_IP int64
+	LOGIN_FAILURE_WINDOW_SECONDS int64
+	LOGIN_BACKOFF_SECONDS int64
+	LOGIN_LOCKOUT_SECONDS int64
+	TRUSTED_PROXIES string
 }
 
 type DB struct {
@@ -39,11 +80,56 @@ func initConfig() Config {
 			ParseTime: true,
 		},
 		PORT: getEnvOrPanic("PORT", "PORT is required"),
-		JWT_EXPIRE_IN_SECONDS: getEnvAsInt("JWT_EXPIRE_IN_SECONDS", 3600 * 24 * 7),
+		JWT_EXPIRE_IN_SECONDS: getEnvAsInt("JWT_EXPIRE_IN_SECONDS", 60 * 15),
 		JWT_SECRET: getEnvOrPanic("JWT_SECRET", "JWT_SECRET is required"),
+		JWT_KEYS_DIR: getEnv("JWT_KEYS_DIR", ""),
+		JWT_SIGNING_KEY_ID: getEnv("JWT_SIGNING_KEY_ID", ""),
+		JWT_ISSUER: getEnv("JWT_ISSUER", "go-ecom"),
+		JWT_AUDIENCE: getEnv("JWT_AUDIENCE", "go-ecom"),
+		REFRESH_TOKEN_TTL_SECONDS: getEnvAsInt("REFRESH_TOKEN_TTL_SECONDS", 3600 * 24 * 30),
+		PRICE_SCHEDULER_INTERVAL_SECONDS: getEnvAsInt("PRICE_SCHEDULER_INTERVAL_SECONDS", 60),
+		PUBLISHER_INTERVAL_SECONDS: getEnvAsInt("PUBLISHER_INTERVAL_SECONDS", 60),
+		DIGITAL_FILES_DIR: getEnv("DIGITAL_FILES_DIR", "files"),
+		DOWNLOAD_SECRET: getEnv("DOWNLOAD_SECRET", deriveSecret(os.Getenv("JWT_SECRET"), "go-ecom download links")),
+		DOWNLOAD_LINK_TTL_SECONDS: getEnvAsInt("DOWNLOAD_LINK_TTL_SECONDS", 3600),
+		MAX_DOWNLOADS_PER_ITEM: getEnvAsInt("MAX_DOWNLOADS_PER_ITEM", 5),
+		RECOMMENDATIONS_INTERVAL_SECONDS: getEnvAsInt("RECOMMENDATIONS_INTERVAL_SECONDS", 3600),
+		RECOMMENDATIONS_MIN_ORDERS: getEnvAsInt("RECOMMENDATIONS_MIN_ORDERS", 2),
+		NOTIFICATION_RATE_LIMIT: getEnvAsInt("NOTIFICATION_RATE_LIMIT", 5),
+		NOTIFICATION_RATE_WINDOW_SECONDS: getEnvAsInt("NOTIFICATION_RATE_WINDOW_SECONDS", 3600),
+		INVITATION_TTL_SECONDS: getEnvAsInt("INVITATION_TTL_SECONDS", 3600 * 24 * 3),
+		PASSWORD_RESET_TTL_SECONDS: getEnvAsInt("PASSWORD_RESET_TTL_SECONDS", 3600),
+		PASSWORD_RESET_REQUEST_LIMIT: getEnvAsInt("PASSWORD_RESET_REQUEST_LIMIT", 3),
+		PASSWORD_RESET_REQUEST_WINDOW_SECONDS: getEnvAsInt("PASSWORD_RESET_REQUEST_WINDOW_SECONDS", 3600),
+		MAIL_DRIVER: getEnv("MAIL_DRIVER", "console"),
+		MAIL_DIR: getEnv("MAIL_DIR", "mail"),
+		MAIL_FROM: getEnv("MAIL_FROM", "no-reply@go-ecom.local"),
+		APP_URL: getEnv("APP_URL", "http://localhost:" + os.Getenv("PORT")),
+		EMAIL_VERIFICATION_TTL_SECONDS: getEnvAsInt("EMAIL_VERIFICATION_TTL_SECONDS", 3600 * 24),
+		EMAIL_VERIFICATION_RESEND_LIMIT: getEnvAsInt("EMAIL_VERIFICATION_RESEND_LIMIT", 3),
+		EMAIL_VERIFICATION_RESEND_WINDOW_SECONDS: getEnvAsInt("EMAIL_VERIFICATION_RESEND_WINDOW_SECONDS", 3600),
+		REQUIRE_VERIFIED_EMAIL_FOR_CHECKOUT: getEnvAsBool("REQUIRE_VERIFIED_EMAIL_FOR_CHECKOUT", false),
+		TOTP_ISSUER: getEnv("TOTP_ISSUER", "go-ecom"),
+		MFA_TOKEN_TTL_SECONDS: getEnvAsInt("MFA_TOKEN_TTL_SECONDS", 60 * 5),
+		REQUIRE_2FA_FOR_ADMINS: getEnvAsBool("REQUIRE_2FA_FOR_ADMINS", false),
+		LOGIN_ATTEMPT_STORE: getEnv("LOGIN_ATTEMPT_STORE", "mysql"),
+		LOGIN_MAX_FAILURES_PER_ACCOUNT: getEnvAsInt("LOGIN_MAX_FAILURES_PER_ACCOUNT", 5),
+		LOGIN_MAX_FAILURES_PER_IP: getEnvAsInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		LOGIN_FAILURE_WINDOW_SECONDS: getEnvAsInt("LOGIN_FAILURE_WINDOW_SECONDS", 3600),
+		LOGIN_BACKOFF_SECONDS: getEnvAsInt("LOGIN_BACKOFF_SECONDS", 1),
+		LOGIN_LOCKOUT_SECONDS: getEnvAsInt("LOGIN_LOCKOUT_SECONDS", 60 * 15),
+		TRUSTED_PROXIES: getEnv("TRUSTED_PROXIES", ""),
 	}
 }
 
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return fallback
+}
+
 func getEnvOrPanic(key, err string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
@@ -52,6 +138,18 @@ func getEnvOrPanic(key, err string) string {
 	panic(err)
 }
 
+// deriveSecret derives a key for one purpose from secret with HKDF-SHA256, so a secret that
+// isn't configured separately doesn't have to be shared as is. Keys for different labels are
+// unrelated, and don't reveal secret.
+func deriveSecret(secret, label string) string {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(label)), key); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(key)
+}
+
 func getEnvAsInt(key string, fallback int64) int64 {
 	if value, ok := os.LookupEnv(key); ok {
 		i, err := strconv.ParseInt(value, 10, 64)
@@ -63,3 +161,15 @@ func getEnvAsInt(key string, fallback int64) int64 {
 	}
 	return fallback
 }
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+
+		return b
+	}
+	return fallback
+}
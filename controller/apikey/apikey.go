@@ -0,0 +1,244 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/controller/audit"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+type Handler struct {
+	store types.APIKeyStore
+	roles types.RoleStore
+	audit types.AuditStore
+}
+
+func NewHandler(store types.APIKeyStore, roleStore types.RoleStore, auditStore types.AuditStore) *Handler {
+	return &Handler{
+		store: store,
+		roles: roleStore,
+		audit: auditStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	apiKeyRouter := router.Group("/api-keys")
+	apiKeyRouter.Use(middleware.JWTAuth(), middleware.RequirePermission(types.PermissionAPIKeysManage))
+	apiKeyRouter.GET("", h.handleGetAPIKeys)
+	apiKeyRouter.POST("", h.handleCreateAPIKey)
+	apiKeyRouter.GET("/:id", h.handleGetAPIKey)
+	apiKeyRouter.DELETE("/:id", h.handleRevokeAPIKey)
+}
+
+// grantablePermissions lists the permissions of whoever is making the request, which are the
+// most an API key they create can have.
+func (h *Handler) grantablePermissions(c *gin.Context) ([]string, error) {
+	if key, ok := c.Get(string(middleware.APIKeyKey)); ok {
+		return key.(*types.APIKey).Scopes, nil
+	}
+
+	role, err := h.roles.GetRole(c.GetString(string(middleware.RoleKey)))
+	if err != nil {
+		return nil, err
+	}
+	return role.Permissions, nil
+}
+
+// handleGetAPIKeys lists all API keys.
+//	@Summary		Get API keys
+//	@Description	List all API keys, including expired and revoked ones, newest first (requires api_keys:manage). The keys themselves are never shown again after they are created.
+//	@Tags			api-keys
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.APIKey		"list of API keys"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/api-keys [get]
+func (h *Handler) handleGetAPIKeys(c *gin.Context) {
+	keys, err := h.store.GetAPIKeys()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, keys)
+}
+
+// handleGetAPIKey gets an API key.
+//	@Summary		Get an API key
+//	@Description	Get an API key by ID (requires api_keys:manage)
+//	@Tags			api-keys
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"API key ID"
+//	@Success		200	{object}	types.APIKey		"API key"
+//	@Failure		400	{object}	map[string]string	"invalid API key ID"
+//	@Failure		404	{object}	map[string]string	"API key not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/api-keys/{id} [get]
+func (h *Handler) handleGetAPIKey(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid API key ID"))
+		return
+	}
+
+	key, err := h.store.GetAPIKeyByID(keyID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, key)
+}
+
+// handleCreateAPIKey creates an API key for an integration.
+//	@Summary		Create an API key
+//	@Description	Create a named API key scoped to some permissions, which must all be permissions of the caller (requires api_keys:manage). The key is only shown in this response; send it in the X-API-Key header.
+//	@Tags			api-keys
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.CreateAPIKeyPayload	true	"API key payload"
+//	@Success		201		{object}	map[string]any				"key and apiKey"
+//	@Failure		400		{object}	map[string]string			"invalid payload or expiry"
+//	@Failure		403		{object}	map[string]string			"scope the caller doesn't have"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/api-keys [post]
+func (h *Handler) handleCreateAPIKey(c *gin.Context) {
+	var payload types.CreateAPIKeyPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("expiresAt must be in the future"))
+		return
+	}
+
+	// Keys can't be given permissions their creator doesn't have
+	grantable, err := h.grantablePermissions(c)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	allowed := make(map[string]bool, len(grantable))
+	for _, permission := range grantable {
+		allowed[permission] = true
+	}
+	scopes := make([]string, 0, len(payload.Scopes))
+	seen := make(map[string]bool, len(payload.Scopes))
+	for _, scope := range payload.Scopes {
+		if !allowed[scope] {
+			utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("you can't grant the %q scope", scope))
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	apiKey := types.APIKey{
+		Name:    payload.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  scopes,
+	}
+	if userID, exists := c.Get(string(middleware.UserKey)); exists {
+		id := userID.(int)
+		apiKey.CreatedBy = &id
+	}
+	if payload.ExpiresAt != nil {
+		expiresAt := payload.ExpiresAt.Truncate(time.Second)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	keyID, err := h.store.CreateAPIKey(apiKey)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetAPIKeyByID(keyID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "api_key.create", "api_key", keyID, map[string]any{"name": created.Name, "scopes": created.Scopes})
+
+	utils.Log.WithFields(logrus.Fields{
+		"apiKeyID": keyID,
+		"prefix":   prefix,
+		"scopes":   scopes,
+	}).Info("API key created")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, map[string]any{"key": key, "apiKey": created})
+}
+
+// handleRevokeAPIKey revokes an API key.
+//	@Summary		Revoke an API key
+//	@Description	Stop an API key from being used, right away (requires api_keys:manage)
+//	@Tags			api-keys
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"API key ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"invalid API key ID"
+//	@Failure		404	{object}	map[string]string	"API key not found"
+//	@Failure		409	{object}	map[string]string	"API key already revoked"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/api-keys/{id} [delete]
+func (h *Handler) handleRevokeAPIKey(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid API key ID"))
+		return
+	}
+
+	if err := h.store.RevokeAPIKey(keyID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "api_key.revoke", "api_key", keyID, nil)
+
+	utils.Log.WithFields(logrus.Fields{
+		"apiKeyID": keyID,
+	}).Info("API key revoked")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "API key revoked"})
+}
+
+// writeStoreError maps API key store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrAPIKeyNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrAPIKeyRevoked):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
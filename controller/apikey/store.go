@@ -0,0 +1,159 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyRevoked  = errors.New("API key has already been revoked")
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+const apiKeyColumns = "id, name, prefix, keyHash, scopes, createdBy, expiresAt, lastUsedAt, revokedAt, createdAt"
+
+func scanAPIKey(row scanner) (*types.APIKey, error) {
+	var k types.APIKey
+	var scopes []byte
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &k.CreatedBy, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode API key scopes: %w", err)
+	}
+	return &k, nil
+}
+
+// CreateAPIKey stores a new API key and returns its ID.
+func (s *Store) CreateAPIKey(k types.APIKey) (int, error) {
+	ctx := context.Background()
+
+	scopes, err := json.Marshal(k.Scopes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode API key scopes: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (name, prefix, keyHash, scopes, createdBy, expiresAt)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, k.Name, k.Prefix, k.KeyHash, scopes, k.CreatedBy, k.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get API key ID: %w", err)
+	}
+
+	return int(id), nil
+}
+
+// GetAPIKeys retrieves all API keys, newest first.
+func (s *Store) GetAPIKeys() ([]types.APIKey, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY createdAt DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]types.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByID retrieves a single API key.
+func (s *Store) GetAPIKeyByID(id int) (*types.APIKey, error) {
+	ctx := context.Background()
+
+	k, err := scanAPIKey(s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return k, nil
+}
+
+// GetAPIKeyByPrefix retrieves the API key with the given prefix.
+func (s *Store) GetAPIKeyByPrefix(prefix string) (*types.APIKey, error) {
+	ctx := context.Background()
+
+	k, err := scanAPIKey(s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = ?", prefix))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return k, nil
+}
+
+// RevokeAPIKey stops an API key from being used.
+func (s *Store) RevokeAPIKey(id int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revokedAt = NOW() WHERE id = ? AND revokedAt IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n == 0 {
+		// Tell a missing key from one that was already revoked
+		if _, err := s.GetAPIKeyByID(id); err != nil {
+			return err
+		}
+		return ErrAPIKeyRevoked
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that an API key was used. To spare a write on every request, the
+// time is only updated once a minute.
+func (s *Store) TouchAPIKey(id int) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET lastUsedAt = NOW()
+		WHERE id = ? AND (lastUsedAt IS NULL OR lastUsedAt < NOW() - INTERVAL 1 MINUTE)
+	`, id); err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,392 @@
+package attribute
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+type Handler struct {
+	store        types.AttributeStore
+	productStore types.ProductStore
+}
+
+func NewHandler(store types.AttributeStore, productStore types.ProductStore) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	// Public routes to browse attributes and search the catalog with them
+	router.GET("/catalog/attributes", h.handleGetAttributes)
+	router.GET("/catalog/products/:id/attributes", h.handleGetCatalogProductAttributes)
+	router.GET("/catalog/search", h.handleSearchProducts)
+
+	// Staff routes to define attributes and assign them to products
+	attributeRouter := router.Group("/attributes")
+	attributeRouter.Use(middleware.JWTAuth(), middleware.RequirePermission(types.PermissionAttributesWrite))
+	attributeRouter.POST("", h.handleCreateAttribute)
+	attributeRouter.PUT("/:id", h.handleUpdateAttribute)
+	attributeRouter.DELETE("/:id", h.handleDeleteAttribute)
+
+	productRouter := router.Group("/products")
+	productRouter.Use(middleware.JWTAuth())
+	productRouter.GET("/:id/attributes", middleware.RequirePermission(types.PermissionProductsRead), h.handleGetProductAttributes)
+	productRouter.PUT("/:id/attributes", middleware.RequirePermission(types.PermissionProductsWrite), h.handleSetProductAttributes)
+}
+
+// handleGetAttributes lists all attributes.
+//	@Summary		Get attributes
+//	@Description	List the attributes products can be described and filtered by
+//	@Tags			attributes
+//	@Produce		json
+//	@Success		200	{array}		types.Attribute		"list of attributes"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/catalog/attributes [get]
+func (h *Handler) handleGetAttributes(c *gin.Context) {
+	attributes, err := h.store.GetAttributes()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, attributes)
+}
+
+// handleCreateAttribute defines a new attribute.
+//	@Summary		Create an attribute
+//	@Description	Define a new text, number or boolean attribute (requires attributes:write)
+//	@Tags			attributes
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.CreateAttributePayload	true	"Attribute payload"
+//	@Success		201		{object}	types.Attribute					"created attribute"
+//	@Failure		400		{object}	map[string]string				"invalid payload"
+//	@Failure		409		{object}	map[string]string				"attribute code already used"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/attributes [post]
+func (h *Handler) handleCreateAttribute(c *gin.Context) {
+	var payload types.CreateAttributePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	attributeID, err := h.store.CreateAttribute(types.Attribute{
+		Code: payload.Code,
+		Name: payload.Name,
+		Type: payload.Type,
+		Unit: payload.Unit,
+	})
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	attribute, err := h.store.GetAttributeByID(attributeID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the creation of the attribute
+	utils.Log.WithFields(logrus.Fields{
+		"attributeID": attributeID,
+		"code":        attribute.Code,
+		"type":        attribute.Type,
+	}).Info("Attribute created")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, attribute)
+}
+
+// handleUpdateAttribute renames an attribute.
+//	@Summary		Update an attribute
+//	@Description	Change the name and unit of an attribute (requires attributes:write). Its code and type can't be changed.
+//	@Tags			attributes
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int								true	"Attribute ID"
+//	@Param			payload	body		types.UpdateAttributePayload	true	"Attribute payload"
+//	@Success		200		{object}	types.Attribute					"updated attribute"
+//	@Failure		400		{object}	map[string]string				"invalid attribute ID or payload"
+//	@Failure		404		{object}	map[string]string				"attribute not found"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/attributes/{id} [put]
+func (h *Handler) handleUpdateAttribute(c *gin.Context) {
+	attributeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid attribute ID"))
+		return
+	}
+
+	var payload types.UpdateAttributePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	if err := h.store.UpdateAttribute(types.Attribute{ID: attributeID, Name: payload.Name, Unit: payload.Unit}); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	attribute, err := h.store.GetAttributeByID(attributeID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, attribute)
+}
+
+// handleDeleteAttribute deletes an attribute.
+//	@Summary		Delete an attribute
+//	@Description	Delete an attribute and remove it from every product (requires attributes:write)
+//	@Tags			attributes
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path	int	true	"Attribute ID"
+//	@Success		204	"no content"
+//	@Failure		400	{object}	map[string]string	"invalid attribute ID"
+//	@Failure		404	{object}	map[string]string	"attribute not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/attributes/{id} [delete]
+func (h *Handler) handleDeleteAttribute(c *gin.Context) {
+	attributeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid attribute ID"))
+		return
+	}
+
+	if err := h.store.DeleteAttribute(attributeID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the deletion
+	utils.Log.WithFields(logrus.Fields{
+		"attributeID": attributeID,
+	}).Info("Attribute deleted")
+
+	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
+}
+
+// handleGetCatalogProductAttributes lists the attribute values of a catalog product.
+//	@Summary		Get catalog product attributes
+//	@Description	Get the attribute values of a product in the catalog
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			id	path		int								true	"Product ID"
+//	@Success		200	{array}		types.ProductAttributeValue		"attribute values"
+//	@Failure		400	{object}	map[string]string				"invalid product ID"
+//	@Failure		404	{object}	map[string]string				"product not found"
+//	@Failure		500	{object}	map[string]string				"internal server error"
+//	@Router			/catalog/products/{id}/attributes [get]
+func (h *Handler) handleGetCatalogProductAttributes(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil || !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+
+	values, err := h.store.GetProductAttributes(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, values)
+}
+
+// handleGetProductAttributes lists the attribute values of a product.
+//	@Summary		Get product attributes
+//	@Description	Get the attribute values of any product (requires products:read)
+//	@Tags			attributes
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int								true	"Product ID"
+//	@Success		200	{array}		types.ProductAttributeValue		"attribute values"
+//	@Failure		400	{object}	map[string]string				"invalid product ID"
+//	@Failure		500	{object}	map[string]string				"internal server error"
+//	@Router			/products/{id}/attributes [get]
+func (h *Handler) handleGetProductAttributes(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	values, err := h.store.GetProductAttributes(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, values)
+}
+
+// handleSetProductAttributes assigns attribute values to a product.
+//	@Summary		Set product attributes
+//	@Description	Replace the attribute values of a product (requires products:write). Values are keyed by attribute code and must match the attribute type: a string for text, a number for number and true or false for boolean attributes.
+//	@Tags			attributes
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int									true	"Product ID"
+//	@Param			payload	body		types.SetProductAttributesPayload	true	"Attribute values"
+//	@Success		200		{array}		types.ProductAttributeValue			"attribute values"
+//	@Failure		400		{object}	map[string]string					"invalid product ID, unknown attribute or invalid value"
+//	@Failure		404		{object}	map[string]string					"product not found"
+//	@Failure		500		{object}	map[string]string					"internal server error"
+//	@Router			/products/{id}/attributes [put]
+func (h *Handler) handleSetProductAttributes(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	var payload types.SetProductAttributesPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	if _, err := h.productStore.GetProductByID(productID); err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+
+	attributes, err := h.attributesByCode()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	values := make([]types.ProductAttributeValue, 0, len(payload.Attributes))
+	for code, raw := range payload.Attributes {
+		attribute, ok := attributes[code]
+		if !ok {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("unknown attribute %q", code))
+			return
+		}
+
+		value, err := normalizeValue(attribute, raw)
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid value for %q: %v", code, err))
+			return
+		}
+		values = append(values, types.ProductAttributeValue{AttributeID: attribute.ID, Value: value})
+	}
+
+	if err := h.store.SetProductAttributes(productID, values); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	updated, err := h.store.GetProductAttributes(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Log the new values
+	utils.Log.WithFields(logrus.Fields{
+		"productID":  productID,
+		"attributes": payload.Attributes,
+	}).Info("Product attributes updated")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, updated)
+}
+
+// attributesByCode retrieves all attributes keyed by their code
+func (h *Handler) attributesByCode() (map[string]types.Attribute, error) {
+	attributes, err := h.store.GetAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]types.Attribute, len(attributes))
+	for _, a := range attributes {
+		byCode[a.Code] = a
+	}
+
+	return byCode, nil
+}
+
+// normalizeValue checks a JSON value against the attribute type and returns how it is stored:
+// text as is, numbers in their shortest decimal form and booleans as "true" or "false".
+func normalizeValue(attribute types.Attribute, raw interface{}) (string, error) {
+	switch attribute.Type {
+	case "number":
+		number, ok := raw.(float64)
+		if !ok {
+			return "", fmt.Errorf("expected a number")
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case "boolean":
+		b, ok := raw.(bool)
+		if !ok {
+			return "", fmt.Errorf("expected true or false")
+		}
+		return strconv.FormatBool(b), nil
+	default:
+		text, ok := raw.(string)
+		if !ok {
+			return "", fmt.Errorf("expected a string")
+		}
+		text = strings.TrimSpace(text)
+		if text == "" || len(text) > 255 {
+			return "", fmt.Errorf("expected 1 to 255 characters")
+		}
+		return text, nil
+	}
+}
+
+// writeStoreError maps attribute store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrAttributeNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrAttributeExists):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,203 @@
+package attribute
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// Search results are paged
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// parseFilter reads the value of an attr[code] query parameter: a comma-separated list
+// of values, or for number attributes a range such as "5..7", "5.." or "..7".
+func parseFilter(attribute types.Attribute, raw string) (types.AttributeFilter, error) {
+	f := types.AttributeFilter{AttributeID: attribute.ID}
+
+	if attribute.Type == "number" && strings.Contains(raw, "..") {
+		bounds := strings.SplitN(raw, "..", 2)
+		for i, bound := range bounds {
+			bound = strings.TrimSpace(bound)
+			if bound == "" {
+				continue
+			}
+			number, err := strconv.ParseFloat(bound, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid range for %q", attribute.Code)
+			}
+			if i == 0 {
+				f.Min = &number
+			} else {
+				f.Max = &number
+			}
+		}
+		return f, nil
+	}
+
+	f.Values = make([]string, 0)
+	for _, value := range strings.Split(raw, ",") {
+		value = strings.TrimSpace(value)
+		if attribute.Type == "number" {
+			// Compare numbers in the form they are stored in
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid number for %q", attribute.Code)
+			}
+			value = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		f.Values = append(f.Values, value)
+	}
+
+	return f, nil
+}
+
+// handleSearchProducts searches the catalog and returns facets for the results.
+//	@Summary		Search the catalog
+//	@Description	Search published products by text and attribute values. Filters are passed as attr[code]=value, with several values separated by commas (any of them matches) and number ranges written as min..max, where either bound can be left out. Values are compared case-insensitively. Facets count, for every attribute, the matching products per value, applying all filters except the one on that attribute; values that only differ in case are counted together.
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			q		query		string						false	"Text to look for in the product name, description or SKU"
+//	@Param			attr	query		string						false	"Attribute filters, e.g. attr[brand]=acme,globex&attr[screen-size]=5..7"
+//	@Param			page	query		int							false	"Page, from 1"
+//	@Param			perPage	query		int							false	"Products per page (default 20, at most 100)"
+//	@Success		200		{object}	types.ProductSearchResult	"matching products and facets"
+//	@Failure		400		{object}	map[string]string			"unknown attribute or invalid filter"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/catalog/search [get]
+func (h *Handler) handleSearchProducts(c *gin.Context) {
+	query := types.ProductSearchQuery{
+		Query:   strings.TrimSpace(c.Query("q")),
+		Filters: make([]types.AttributeFilter, 0),
+		Page:    1,
+		PerPage: defaultPerPage,
+	}
+
+	var err error
+	if raw := c.Query("page"); raw != "" {
+		if query.Page, err = strconv.Atoi(raw); err != nil || query.Page < 1 {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("page must be a positive number"))
+			return
+		}
+	}
+	if raw := c.Query("perPage"); raw != "" {
+		if query.PerPage, err = strconv.Atoi(raw); err != nil || query.PerPage < 1 || query.PerPage > maxPerPage {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("perPage must be between 1 and %d", maxPerPage))
+			return
+		}
+	}
+
+	attributes, err := h.store.GetAttributes()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	byCode := make(map[string]types.Attribute, len(attributes))
+	for _, a := range attributes {
+		byCode[strings.ToLower(a.Code)] = a
+	}
+
+	filtered := make(map[int]bool)
+	for code, raw := range c.QueryMap("attr") {
+		attribute, ok := byCode[strings.ToLower(code)]
+		if !ok {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("unknown attribute %q", code))
+			return
+		}
+		if filtered[attribute.ID] {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("attribute %q is filtered more than once", code))
+			return
+		}
+		filtered[attribute.ID] = true
+
+		f, err := parseFilter(attribute, raw)
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusBadRequest, err)
+			return
+		}
+		query.Filters = append(query.Filters, f)
+	}
+
+	productIDs, total, err := h.store.SearchProducts(query)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := types.ProductSearchResult{
+		Total:    total,
+		Page:     query.Page,
+		PerPage:  query.PerPage,
+		Products: make([]*types.Product, 0, len(productIDs)),
+		Facets:   make([]types.Facet, 0),
+	}
+
+	if len(productIDs) > 0 {
+		products, err := h.productStore.GetProductsByIDs(productIDs)
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return
+		}
+		byID := make(map[int]types.Product, len(products))
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+		// Keep the order of the search
+		for _, id := range productIDs {
+			if p, ok := byID[id]; ok {
+				result.Products = append(result.Products, &p)
+			}
+		}
+	}
+
+	counts, err := h.store.CountFacetValues(query)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	values := make(map[int][]types.FacetValue)
+	for _, count := range counts {
+		values[count.AttributeID] = append(values[count.AttributeID], types.FacetValue{Value: count.Value, Count: count.Count})
+	}
+
+	for _, attribute := range attributes {
+		if len(values[attribute.ID]) == 0 {
+			continue
+		}
+
+		facet := types.Facet{
+			Code:   attribute.Code,
+			Name:   attribute.Name,
+			Type:   attribute.Type,
+			Unit:   attribute.Unit,
+			Values: values[attribute.ID],
+		}
+		sortFacetValues(attribute, facet.Values)
+		result.Facets = append(result.Facets, facet)
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, result)
+}
+
+// sortFacetValues orders number values numerically and other values by count, most common first
+func sortFacetValues(attribute types.Attribute, values []types.FacetValue) {
+	sort.Slice(values, func(i, j int) bool {
+		if attribute.Type == "number" {
+			a, _ := strconv.ParseFloat(values[i].Value, 64)
+			b, _ := strconv.ParseFloat(values[j].Value, 64)
+			return a < b
+		}
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+}
@@ -0,0 +1,347 @@
+package attribute
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var (
+	ErrAttributeNotFound = errors.New("attribute not found")
+	ErrAttributeExists   = errors.New("an attribute with this code already exists")
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetAttributes retrieves all attributes ordered by name.
+func (s *Store) GetAttributes() ([]types.Attribute, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, code, name, type, unit, createdAt FROM attributes ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attributes: %w", err)
+	}
+	defer rows.Close()
+
+	attributes := make([]types.Attribute, 0)
+	for rows.Next() {
+		var a types.Attribute
+		if err := rows.Scan(&a.ID, &a.Code, &a.Name, &a.Type, &a.Unit, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan attribute: %w", err)
+		}
+		attributes = append(attributes, a)
+	}
+
+	return attributes, rows.Err()
+}
+
+// GetAttributeByID retrieves a single attribute.
+func (s *Store) GetAttributeByID(id int) (*types.Attribute, error) {
+	ctx := context.Background()
+
+	var a types.Attribute
+	err := s.db.QueryRowContext(ctx, "SELECT id, code, name, type, unit, createdAt FROM attributes WHERE id = ?", id).
+		Scan(&a.ID, &a.Code, &a.Name, &a.Type, &a.Unit, &a.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttributeNotFound
+		}
+		return nil, fmt.Errorf("failed to get attribute: %w", err)
+	}
+
+	return &a, nil
+}
+
+// CreateAttribute stores a new attribute and returns its ID.
+func (s *Store) CreateAttribute(a types.Attribute) (int, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO attributes (code, name, type, unit)
+		VALUES (?, ?, ?, ?)
+	`, a.Code, a.Name, a.Type, a.Unit)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return 0, ErrAttributeExists
+		}
+		return 0, fmt.Errorf("failed to create attribute: %w", err)
+	}
+
+	attributeID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return int(attributeID), nil
+}
+
+// UpdateAttribute changes the name and unit of an attribute.
+func (s *Store) UpdateAttribute(a types.Attribute) error {
+	ctx := context.Background()
+
+	if _, err := s.GetAttributeByID(a.ID); err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE attributes SET name = ?, unit = ? WHERE id = ?", a.Name, a.Unit, a.ID); err != nil {
+		return fmt.Errorf("failed to update attribute: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAttribute deletes an attribute along with its product values.
+func (s *Store) DeleteAttribute(id int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM attributes WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attribute: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete attribute: %w", err)
+	}
+	if n == 0 {
+		return ErrAttributeNotFound
+	}
+
+	return nil
+}
+
+// GetProductAttributes retrieves the attribute values of a product.
+func (s *Store) GetProductAttributes(productID int) ([]types.ProductAttributeValue, error) {
+	return s.GetProductsAttributes([]int{productID})
+}
+
+// GetProductsAttributes retrieves the attribute values of several products at once.
+func (s *Store) GetProductsAttributes(productIDs []int) ([]types.ProductAttributeValue, error) {
+	values := make([]types.ProductAttributeValue, 0)
+	if len(productIDs) == 0 {
+		return values, nil
+	}
+
+	ctx := context.Background()
+
+	placeholders := make([]string, len(productIDs))
+	args := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT pav.productId, a.id, a.code, a.name, a.type, a.unit, pav.value
+		FROM product_attribute_values pav
+		JOIN attributes a ON a.id = pav.attributeId
+		WHERE pav.productId IN (%s)
+		ORDER BY pav.productId, a.name
+	`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product attributes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v types.ProductAttributeValue
+		if err := rows.Scan(&v.ProductID, &v.AttributeID, &v.Code, &v.Name, &v.Type, &v.Unit, &v.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan product attribute: %w", err)
+		}
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
+
+// SetProductAttributes replaces the attribute values of a product.
+func (s *Store) SetProductAttributes(productID int, values []types.ProductAttributeValue) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to set product attributes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_attribute_values WHERE productId = ?", productID); err != nil {
+		return fmt.Errorf("failed to set product attributes: %w", err)
+	}
+
+	for _, v := range values {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO product_attribute_values (productId, attributeId, value)
+			VALUES (?, ?, ?)
+		`, productID, v.AttributeID, v.Value); err != nil {
+			return fmt.Errorf("failed to set product attributes: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique constraint violation.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// numberPattern matches the attribute values number filters can compare
+const numberPattern = `^-?[0-9]+(\.[0-9]+)?$`
+
+// SearchProducts lists the IDs of the published products matching a catalog search, in order of
+// their ID, one page at a time. It also returns how many products match in total.
+func (s *Store) SearchProducts(query types.ProductSearchQuery) ([]int, int, error) {
+	ctx := context.Background()
+
+	condition, args := searchCondition(query, 0)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products p WHERE "+condition, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id
+		FROM products p
+		WHERE `+condition+`
+		ORDER BY p.id
+		LIMIT ? OFFSET ?
+	`, append(args, query.PerPage, (query.Page-1)*query.PerPage)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan product: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, total, rows.Err()
+}
+
+// CountFacetValues counts, for every attribute, the products matching a catalog search per value,
+// applying all filters except the one on that attribute. Values that only differ in case are
+// counted together, under one of their spellings.
+func (s *Store) CountFacetValues(query types.ProductSearchQuery) ([]types.FacetCount, error) {
+	counts := make([]types.FacetCount, 0)
+
+	// Attributes without a filter are all counted over the products matching every filter
+	condition, args := searchCondition(query, 0)
+	if len(query.Filters) > 0 {
+		placeholders := make([]string, len(query.Filters))
+		for i, f := range query.Filters {
+			placeholders[i] = "?"
+			args = append(args, f.AttributeID)
+		}
+		condition += fmt.Sprintf(" AND pav.attributeId NOT IN (%s)", strings.Join(placeholders, ","))
+	}
+	counts, err := s.appendFacetCounts(counts, condition, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filtered attributes are counted one by one, without their own filter
+	for _, f := range query.Filters {
+		condition, args := searchCondition(query, f.AttributeID)
+		counts, err = s.appendFacetCounts(counts, condition+" AND pav.attributeId = ?", append(args, f.AttributeID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
+
+// appendFacetCounts counts the attribute values of the products p matching condition.
+func (s *Store) appendFacetCounts(counts []types.FacetCount, condition string, args []interface{}) ([]types.FacetCount, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pav.attributeId, MIN(pav.value), COUNT(*)
+		FROM product_attribute_values pav
+		JOIN products p ON p.id = pav.productId
+		WHERE `+condition+`
+		GROUP BY pav.attributeId, LOWER(pav.value)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count facet values: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count types.FacetCount
+		if err := rows.Scan(&count.AttributeID, &count.Value, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan facet value: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// searchCondition builds the WHERE clause matching the products p of a catalog search. The
+// filter on the attribute with ID skip is left out.
+func searchCondition(query types.ProductSearchQuery, skip int) (string, []interface{}) {
+	where := []string{"p.status = ?", "p.deletedAt IS NULL"}
+	args := []interface{}{types.ProductStatusPublished}
+
+	if query.Query != "" {
+		pattern := "%" + escapeLike(query.Query) + "%"
+		where = append(where, "(p.name LIKE ? OR p.description LIKE ? OR p.sku LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	for _, f := range query.Filters {
+		if f.AttributeID == skip {
+			continue
+		}
+
+		conditions := []string{"f.productId = p.id", "f.attributeId = ?"}
+		args = append(args, f.AttributeID)
+		if f.Values != nil {
+			placeholders := make([]string, len(f.Values))
+			for i, value := range f.Values {
+				placeholders[i] = "?"
+				args = append(args, strings.ToLower(value))
+			}
+			conditions = append(conditions, fmt.Sprintf("LOWER(f.value) IN (%s)", strings.Join(placeholders, ",")))
+		} else {
+			conditions = append(conditions, "f.value REGEXP ?")
+			args = append(args, numberPattern)
+			if f.Min != nil {
+				conditions = append(conditions, "CAST(f.value AS DECIMAL(65, 10)) >= ?")
+				args = append(args, *f.Min)
+			}
+			if f.Max != nil {
+				conditions = append(conditions, "CAST(f.value AS DECIMAL(65, 10)) <= ?")
+				args = append(args, *f.Max)
+			}
+		}
+
+		where = append(where, "EXISTS (SELECT 1 FROM product_attribute_values f WHERE "+strings.Join(conditions, " AND ")+")")
+	}
+
+	return strings.Join(where, " AND "), args
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so they match literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
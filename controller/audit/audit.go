@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Handler struct {
+	store types.AuditStore
+}
+
+func NewHandler(store types.AuditStore) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	auditRouter := router.Group("/audit-logs")
+	auditRouter.Use(middleware.JWTAuth(), middleware.RequirePermission(types.PermissionAuditRead))
+	auditRouter.GET("", h.handleGetAuditLogs)
+}
+
+// Record stores an audit log for an action the authenticated admin took on a target. Targets
+// without a numeric ID, such as roles, pass 0 and name themselves in details. Actions taken
+// with an API key have no actor and name the key in details instead. Failing to record it
+// doesn't undo the action, so errors are logged rather than returned.
+func Record(c *gin.Context, store types.AuditStore, action, targetType string, targetID int, details map[string]any) {
+	actorID := c.GetInt(string(middleware.UserKey))
+	log := types.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		Details:    details,
+		IP:         c.ClientIP(),
+	}
+	if actorID != 0 {
+		log.ActorID = &actorID
+	}
+	if key, ok := c.Get(string(middleware.APIKeyKey)); ok {
+		if log.Details == nil {
+			log.Details = make(map[string]any)
+		}
+		log.Details["apiKeyID"] = key.(*types.APIKey).ID
+	}
+	if targetID != 0 {
+		log.TargetID = &targetID
+	}
+
+	if err := store.CreateAuditLog(log); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"action":     action,
+			"targetType": targetType,
+			"targetID":   targetID,
+			"actorID":    actorID,
+			"error":      err,
+		}).Error("Failed to record audit log")
+	}
+}
+
+// handleGetAuditLogs lists the actions admins took.
+//	@Summary		Get audit logs
+//	@Description	List the actions admins took, newest first (requires audit:read)
+//	@Tags			audit
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			actorId		query		int					false	"Only actions by this admin"
+//	@Param			targetType	query		string				false	"Only actions on this kind of target, e.g. user"
+//	@Param			targetId	query		int					false	"Only actions on this target"
+//	@Param			action		query		string				false	"Only this action, e.g. user.disable"
+//	@Param			limit		query		int					false	"Maximum number of logs (default 50, at most 500)"
+//	@Success		200			{array}		types.AuditLog		"audit logs"
+//	@Failure		400			{object}	map[string]string	"invalid query"
+//	@Failure		500			{object}	map[string]string	"internal server error"
+//	@Router			/audit-logs [get]
+func (h *Handler) handleGetAuditLogs(c *gin.Context) {
+	query := types.AuditLogQuery{
+		TargetType: c.Query("targetType"),
+		Action:     c.Query("action"),
+		Limit:      defaultLimit,
+	}
+
+	var err error
+	if raw := c.Query("actorId"); raw != "" {
+		if query.ActorID, err = strconv.Atoi(raw); err != nil {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid actor ID"))
+			return
+		}
+	}
+	if raw := c.Query("targetId"); raw != "" {
+		if query.TargetID, err = strconv.Atoi(raw); err != nil {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid target ID"))
+			return
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		if query.Limit, err = strconv.Atoi(raw); err != nil || query.Limit < 1 || query.Limit > maxLimit {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxLimit))
+			return
+		}
+	}
+
+	logs, err := h.store.GetAuditLogs(query)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, logs)
+}
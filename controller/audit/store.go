@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateAuditLog records an action.
+func (s *Store) CreateAuditLog(log types.AuditLog) error {
+	ctx := context.Background()
+
+	var details []byte
+	if len(log.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(log.Details); err != nil {
+			return fmt.Errorf("failed to encode audit log details: %w", err)
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_logs (actorId, action, targetType, targetId, details, ip)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, log.ActorID, log.Action, log.TargetType, log.TargetID, details, log.IP); err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditLogs retrieves the audit logs matching the query, newest first.
+func (s *Store) GetAuditLogs(query types.AuditLogQuery) ([]types.AuditLog, error) {
+	ctx := context.Background()
+
+	where := []string{"1 = 1"}
+	args := []any{}
+	if query.ActorID != 0 {
+		where = append(where, "actorId = ?")
+		args = append(args, query.ActorID)
+	}
+	if query.TargetType != "" {
+		where = append(where, "targetType = ?")
+		args = append(args, query.TargetType)
+	}
+	if query.TargetID != 0 {
+		where = append(where, "targetId = ?")
+		args = append(args, query.TargetID)
+	}
+	if query.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, query.Action)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, actorId, action, targetType, targetId, details, ip, createdAt
+		FROM audit_logs
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ?
+	`, append(args, query.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := make([]types.AuditLog, 0)
+	for rows.Next() {
+		var log types.AuditLog
+		var actorID, targetID sql.NullInt64
+		var details []byte
+		if err := rows.Scan(
+			&log.ID,
+			&actorID,
+			&log.Action,
+			&log.TargetType,
+			&targetID,
+			&details,
+			&log.IP,
+			&log.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			log.ActorID = &id
+		}
+		if targetID.Valid {
+			id := int(targetID.Int64)
+			log.TargetID = &id
+		}
+		if details != nil {
+			if err := json.Unmarshal(details, &log.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode audit log details: %w", err)
+			}
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// invitationPurpose marks invitation tokens so they can't be mistaken for any other token
+const invitationPurpose = "invite"
+
+// CreateInvitationToken signs a token that accepts the given invitation until expiresAt.
+func CreateInvitationToken(secret []byte, invitationID int, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"purpose":      invitationPurpose,
+		"invitationID": invitationID,
+		"exp":          expiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign invitation token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ParseInvitationToken checks the signature and expiry of an invitation token and returns
+// the ID of the invitation it accepts.
+func ParseInvitationToken(secret []byte, tokenString string) (int, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid or expired invitation token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	invitationID, ok := claims["invitationID"].(float64)
+	if claims["purpose"] != invitationPurpose || !ok {
+		return 0, fmt.Errorf("invalid or expired invitation token")
+	}
+
+	return int(invitationID), nil
+}
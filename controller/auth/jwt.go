@@ -2,30 +2,110 @@ package auth
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/youngprinnce/go-ecom/config"
 )
 
-// CreateJWT generates a new JWT token for the given user ID and role.
-func CreateJWT(secret []byte, userID int, role string) (string, error) {
+// Authentication methods (RFC 8176) recorded in the amr claim of access tokens
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+)
+
+// Claims are the claims of an access token. The user ID is the subject.
+type Claims struct {
+	Role        string   `json:"role"`
+	SessionID   string   `json:"sid"`           // session the token can be revoked with
+	AuthMethods []string `json:"amr,omitempty"` // how the user authenticated
+	jwt.RegisteredClaims
+}
+
+// UserID returns the ID of the user the token was issued to.
+func (c *Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// keys signs and verifies access tokens. It uses JWT_SECRET until SetKeySet is called.
+var keys = NewHMACKeySet([]byte(config.Envs.JWT_SECRET))
+
+// SetKeySet replaces the keys access tokens are signed and verified with.
+func SetKeySet(set *KeySet) {
+	keys = set
+}
+
+// JWKS lists the public keys access tokens can be verified with.
+func JWKS() []JWK {
+	return keys.JWKS()
+}
+
+// HasAuthMethod reports whether the user authenticated with the given method.
+func (c *Claims) HasAuthMethod(method string) bool {
+	for _, m := range c.AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateJWT generates a new JWT token for the given user ID and role, belonging to the given
+// session whose user authenticated with authMethods.
+func CreateJWT(userID int, role string, sessionID string, authMethods []string) (string, error) {
 	expiration := time.Second * time.Duration(config.Envs.JWT_EXPIRE_IN_SECONDS)
+	now := time.Now()
+
 	// Create the JWT claims
-	claims := jwt.MapClaims{
-		"userID": userID,                            // Include user ID in the claims
-		"role":   role,                              // Include user role in the claims
-		"expiresAt":    time.Now().Add(expiration).Unix(), // Token expires in 7 days
+	claims := Claims{
+		Role:        role,
+		SessionID:   sessionID,
+		AuthMethods: authMethods,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.Envs.JWT_ISSUER,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{config.Envs.JWT_AUDIENCE},
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)), // Access tokens are short-lived, clients refresh them
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
 	}
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create the token, naming the key so verifiers can pick it out of the key set
+	signing := keys.signing
+	token := jwt.NewWithClaims(signing.Method, claims)
+	if signing.ID != "" {
+		token.Header["kid"] = signing.ID
+	}
 
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString(secret)
+	// Sign the token with the current signing key
+	tokenString, err := token.SignedString(signing.signKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign JWT: %w", err)
 	}
 
 	return tokenString, nil
 }
+
+// ParseJWT verifies an access token and returns its claims. The signature must come from a key
+// in the key set, with that key's algorithm, and the token must be current and issued by and
+// for this API.
+func ParseJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.keyfunc,
+		jwt.WithValidMethods(keys.Methods()),
+		jwt.WithIssuer(config.Envs.JWT_ISSUER),
+		jwt.WithAudience(config.Envs.JWT_AUDIENCE),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
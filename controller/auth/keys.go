@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key signs or verifies access tokens. Keys without a private part only verify tokens,
+// which keeps tokens signed by a retired key valid until they expire.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// signKey is nil for verify-only keys
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet holds the keys access tokens are verified with, by key ID, and the one new tokens
+// are signed with.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewHMACKeySet creates a key set that signs and verifies tokens with a shared secret (HS256).
+func NewHMACKeySet(secret []byte) *KeySet {
+	key := &Key{Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+	return &KeySet{
+		signing: key,
+		keys:    map[string]*Key{"": key},
+	}
+}
+
+// LoadKeySet loads the PEM encoded keys in dir, each named after its key ID (e.g. 2024-06.pem).
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA. Private keys may be PKCS #8 or PKCS #1,
+// public keys PKIX. New tokens are signed with the private key signingKeyID, or when that is
+// empty with the private key whose ID sorts last, so rotating means adding a newer key file.
+func LoadKeySet(dir string, signingKeyID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	sort.Strings(paths)
+
+	set := &KeySet{keys: make(map[string]*Key)}
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		set.keys[key.ID] = key
+
+		if key.signKey != nil && (signingKeyID == "" || key.ID == signingKeyID) {
+			set.signing = key
+		}
+	}
+
+	if set.signing == nil {
+		if signingKeyID != "" {
+			return nil, fmt.Errorf("no private key %q in %s", signingKeyID, dir)
+		}
+		return nil, fmt.Errorf("no private key in %s", dir)
+	}
+
+	return set, nil
+}
+
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T, use RSA or Ed25519", path, parsed)
+	}
+
+	return key, nil
+}
+
+// Methods lists the signing algorithms of the keys in the set.
+func (s *KeySet) Methods() []string {
+	seen := make(map[string]bool)
+	methods := make([]string, 0, 2)
+	for _, key := range s.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// keyfunc finds the key a token was signed with from its kid header.
+func (s *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS lists the public keys of the set, ordered by key ID. Shared secrets are never listed.
+func (s *KeySet) JWKS() []JWK {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := make([]JWK, 0, len(ids))
+	for _, id := range ids {
+		key := s.keys[id]
+		jwk := JWK{KeyID: id, Use: "sig", Algorithm: key.Method.Alg()}
+		switch k := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
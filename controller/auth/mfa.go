@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mfaPurpose marks tokens that stand for a login waiting for its second factor
+const mfaPurpose = "mfa"
+
+// CreateMFAToken signs a token showing that the given user got their password right, which
+// completes the login together with a second factor until expiresAt.
+func CreateMFAToken(secret []byte, userID int, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"purpose": mfaPurpose,
+		"userID":  userID,
+		"exp":     expiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign MFA token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ParseMFAToken checks the signature and expiry of an MFA token and returns the ID of the
+// user logging in.
+func ParseMFAToken(secret []byte, tokenString string) (int, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid or expired MFA token, log in again")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	userID, ok := claims["userID"].(float64)
+	if claims["purpose"] != mfaPurpose || !ok {
+		return 0, fmt.Errorf("invalid or expired MFA token, log in again")
+	}
+
+	return int(userID), nil
+}
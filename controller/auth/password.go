@@ -1,6 +1,10 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -15,3 +19,17 @@ func ComparePasswords(hashed string, plain []byte) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashed), plain)
 	return err == nil
 }
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// CompareDummyPassword takes as long as ComparePasswords but never matches. Logins for unknown
+// emails call it so response times don't tell which emails have accounts.
+func CompareDummyPassword(plain []byte) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, plain)
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// NewRecoveryCodes generates n one-time recovery codes, formatted like 3f9a2-c81d0.
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		code := fmt.Sprintf("%x", b)
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode lowercases a recovery code and removes separators, so codes match
+// however they were typed. Codes are hashed in this form.
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, which tells keys apart from access tokens and lets
+// secret scanners spot leaked ones.
+const APIKeyPrefix = "gek_"
+
+// NewSessionID generates a random ID for a new session.
+func NewSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// NewToken generates a random token, such as a refresh or password reset token, and the
+// hash it is stored under.
+func NewToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes a random token for storage. Tokens carry enough entropy that a fast,
+// unsalted hash is enough to keep a database leak from exposing them.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey generates an API key such as gek_1a2b3c4d_<secret>. Its prefix (gek_1a2b3c4d)
+// identifies the key and is stored as is, the whole key only as its hash.
+func NewAPIKey() (key string, prefix string, hash string, err error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	secret, _, err := NewToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	key = prefix + "_" + secret
+	return key, prefix, HashToken(key), nil
+}
+
+// APIKeyPrefixOf returns the prefix identifying an API key, or false if key isn't an API key.
+func APIKeyPrefixOf(key string) (string, bool) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || len(id) != 8 || secret == "" {
+		return "", false
+	}
+	return APIKeyPrefix + id, true
+}
@@ -0,0 +1,141 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890" in ASCII,
+// base32 encoded the way secrets are stored.
+var rfc6238Secret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// The SHA-1 test vectors of RFC 6238 appendix B. The RFC lists 8 digit codes, these are
+// their last 6 digits, which is what a 6 digit TOTP yields for the same time.
+var rfc6238Vectors = []struct {
+	unix int64
+	step int64
+	code string
+}{
+	{59, 0x1, "287082"},
+	{1111111109, 0x23523EC, "081804"},
+	{1111111111, 0x23523ED, "050471"},
+	{1234567890, 0x273EF07, "005924"},
+	{2000000000, 0x3F940AA, "279037"},
+	{20000000000, 0x27BC86AA, "353130"},
+}
+
+func TestValidateVectors(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		t.Run(tt.code, func(t *testing.T) {
+			step, ok := Validate(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+			if !ok {
+				t.Fatalf("code %s rejected at %d", tt.code, tt.unix)
+			}
+			if step != tt.step {
+				t.Errorf("step = %#x, want %#x", step, tt.step)
+			}
+		})
+	}
+}
+
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, tt := range rfc6238Vectors {
+		if got := hotp(key, tt.step); got != tt.code {
+			t.Errorf("hotp(%#x) = %s, want %s", tt.step, got, tt.code)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	// "081804" belongs to step 0x23523EC, which covers 1111111090 to 1111111119
+	const code = "081804"
+	const step = 0x23523EC
+	start := time.Unix(step*30, 0)
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		valid bool
+	}{
+		{"start of its step", start, true},
+		{"end of its step", start.Add(29 * time.Second), true},
+		{"one step late", start.Add(30 * time.Second), true},
+		{"end of one step late", start.Add(59 * time.Second), true},
+		{"one step early", start.Add(-30 * time.Second), true},
+		{"two steps late", start.Add(60 * time.Second), false},
+		{"just over one step early", start.Add(-31 * time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfc6238Secret, code, tt.at)
+			if ok != tt.valid {
+				t.Fatalf("valid = %v, want %v", ok, tt.valid)
+			}
+			if ok && got != step {
+				t.Errorf("step = %#x, want %#x", got, step)
+			}
+		})
+	}
+}
+
+// Codes can only be used once because callers store the step Validate returns and
+// refuse steps that aren't newer than the last one used. That only works if a code resolves
+// to the same step however late it is replayed within the skew window.
+func TestValidateReplay(t *testing.T) {
+	const code = "050471"
+	at := time.Unix(1111111111, 0)
+
+	first, ok := Validate(rfc6238Secret, code, at)
+	if !ok {
+		t.Fatal("code rejected on first use")
+	}
+
+	replayed, ok := Validate(rfc6238Secret, code, at.Add(30*time.Second))
+	if !ok {
+		t.Fatal("code rejected within the skew window")
+	}
+	if replayed != first {
+		t.Errorf("replayed code resolved to step %#x, first use to %#x", replayed, first)
+	}
+
+	// The code of the following step is a new step, so it is accepted after the first one
+	next := hotp([]byte("12345678901234567890"), first+1)
+	step, ok := Validate(rfc6238Secret, next, at.Add(30*time.Second))
+	if !ok || step <= first {
+		t.Errorf("next code resolved to step %#x (valid %v), want a step after %#x", step, ok, first)
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfc6238Secret, "287083"},
+		{"8 digit code", rfc6238Secret, "94287082"},
+		{"short code", rfc6238Secret, "28708"},
+		{"empty code", rfc6238Secret, ""},
+		{"invalid secret", "not base32!", "287082"},
+		{"empty secret", "", "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, at); ok {
+				t.Errorf("code %q accepted", tt.code)
+			}
+		})
+	}
+}
+
+func TestValidateLowercaseSecret(t *testing.T) {
+	if _, ok := Validate(strings.ToLower(rfc6238Secret), "287082", time.Unix(59, 0)); !ok {
+		t.Error("code rejected for a lowercase secret")
+	}
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SignEmailVerification computes the signature of a link that verifies the given email of a
+// user until expires. Signing the email means the link stops working if the email changes.
+func SignEmailVerification(secret []byte, userID int, email string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "verify-email:%d:%s:%d", userID, email, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckEmailVerification reports whether signature is a valid signature of a link that
+// verifies the given email of a user until expires. Expiry is checked by the caller.
+func CheckEmailVerification(secret []byte, userID int, email string, expires int64, signature string) bool {
+	expected := SignEmailVerification(secret, userID, email, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
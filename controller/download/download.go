@@ -0,0 +1,387 @@
+package download
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+type Handler struct {
+	store        types.DownloadStore
+	productStore types.ProductStore
+	orderStore   types.OrderStore
+	// downloadPath is the path download links point to, set when the routes are registered
+	downloadPath string
+}
+
+func NewHandler(store types.DownloadStore, productStore types.ProductStore, orderStore types.OrderStore) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+		orderStore:   orderStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	// Staff routes to manage the files of digital products
+	fileRouter := router.Group("/products")
+	fileRouter.Use(middleware.JWTAuth())
+	fileRouter.GET("/:id/files", middleware.RequirePermission(types.PermissionProductsRead), h.handleGetProductFiles)
+	fileRouter.POST("/:id/files", middleware.RequirePermission(types.PermissionProductsWrite), h.handleUploadProductFile)
+	fileRouter.DELETE("/:id/files/:fileId", middleware.RequirePermission(types.PermissionProductsWrite), h.handleDeleteProductFile)
+
+	// Customers get signed links for the files they bought
+	router.GET("/orders/:id/downloads", middleware.JWTAuth(), h.handleGetOrderDownloads)
+
+	// Download links are authorized by their signature, not by a token
+	h.downloadPath = router.BasePath() + "/downloads"
+	router.GET("/downloads/:itemId/:fileId", h.handleDownload)
+}
+
+// handleGetProductFiles lists the files of a digital product.
+//	@Summary		Get product files
+//	@Description	Get the files customers can download after buying a digital product (requires products:read)
+//	@Tags			downloads
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Product ID"
+//	@Success		200	{array}		types.ProductFile	"product files"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/files [get]
+func (h *Handler) handleGetProductFiles(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	files, err := h.store.GetProductFiles(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, files)
+}
+
+// handleUploadProductFile attaches a file to a digital product.
+//	@Summary		Upload a product file
+//	@Description	Attach a file to a digital product (requires products:write). The file is stored in the digital files directory and offered to customers under its original name.
+//	@Tags			downloads
+//	@Accept			mpfd
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int					true	"Product ID"
+//	@Param			file	formData	file				true	"File"
+//	@Success		201		{object}	types.ProductFile	"uploaded file"
+//	@Failure		400		{object}	map[string]string	"invalid product ID, missing file or product not digital"
+//	@Failure		404		{object}	map[string]string	"product not found"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/files [post]
+func (h *Handler) handleUploadProductFile(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+	if !product.IsDigital {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("product is not digital"))
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("missing file: %v", err))
+		return
+	}
+
+	// Store the file under a random name so uploads can't overwrite or point at other files
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	file := types.ProductFile{
+		ProductID: productID,
+		Name:      filepath.Base(header.Filename),
+		Path:      hex.EncodeToString(name) + filepath.Ext(header.Filename),
+		Size:      header.Size,
+	}
+
+	if err := os.MkdirAll(config.Envs.DIGITAL_FILES_DIR, 0o750); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	if err := c.SaveUploadedFile(header, filepath.Join(config.Envs.DIGITAL_FILES_DIR, file.Path)); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	if file.ID, err = h.store.CreateProductFile(file); err != nil {
+		os.Remove(filepath.Join(config.Envs.DIGITAL_FILES_DIR, file.Path))
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	file.CreatedAt = time.Now()
+
+	// Log the upload
+	utils.Log.WithFields(logrus.Fields{
+		"productID": productID,
+		"fileID":    file.ID,
+		"name":      file.Name,
+		"size":      file.Size,
+	}).Info("Product file uploaded")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, file)
+}
+
+// handleDeleteProductFile removes a file from a digital product.
+//	@Summary		Delete a product file
+//	@Description	Remove a file from a digital product and delete it from storage (requires products:write)
+//	@Tags			downloads
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path	int	true	"Product ID"
+//	@Param			fileId	path	int	true	"File ID"
+//	@Success		204		"no content"
+//	@Failure		400		{object}	map[string]string	"invalid ID"
+//	@Failure		404		{object}	map[string]string	"file not found"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/files/{fileId} [delete]
+func (h *Handler) handleDeleteProductFile(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	fileID, err := strconv.Atoi(c.Param("fileId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid file ID"))
+		return
+	}
+
+	file, err := h.store.GetProductFile(productID, fileID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	if err := h.store.DeleteProductFile(productID, fileID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(config.Envs.DIGITAL_FILES_DIR, file.Path)); err != nil && !os.IsNotExist(err) {
+		utils.Log.WithFields(logrus.Fields{
+			"fileID": fileID,
+			"error":  err,
+		}).Error("Failed to remove product file from storage")
+	}
+
+	// Log the deletion
+	utils.Log.WithFields(logrus.Fields{
+		"productID": productID,
+		"fileID":    fileID,
+	}).Info("Product file deleted")
+
+	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
+}
+
+// handleGetOrderDownloads creates download links for the digital products of an order.
+//	@Summary		Get download links
+//	@Description	Get signed, time-limited download links for the files of the digital products in a paid order
+//	@Tags			downloads
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Order ID"
+//	@Success		200	{array}		types.DownloadLink	"download links"
+//	@Failure		400	{object}	map[string]string	"invalid order ID"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		403	{object}	map[string]string	"order not paid"
+//	@Failure		404	{object}	map[string]string	"order not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/orders/{id}/downloads [get]
+func (h *Handler) handleGetOrderDownloads(c *gin.Context) {
+	// Retrieve userID from the request context
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid order ID"))
+		return
+	}
+
+	// Other users' orders are reported as missing rather than forbidden
+	order, err := h.orderStore.GetOrderByID(orderID)
+	if err != nil || order.UserID != userID.(int) {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("order not found"))
+		return
+	}
+
+	if !isPaid(order.Status) {
+		utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("downloads are available once the order is paid"))
+		return
+	}
+
+	files, err := h.store.GetDownloadableFiles(orderID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	maxDownloads := int(config.Envs.MAX_DOWNLOADS_PER_ITEM)
+	expiresAt := time.Now().Add(time.Duration(config.Envs.DOWNLOAD_LINK_TTL_SECONDS) * time.Second).Truncate(time.Second)
+	links := make([]types.DownloadLink, 0, len(files))
+	for _, file := range files {
+		links = append(links, types.DownloadLink{
+			OrderItemID:        file.OrderItemID,
+			ProductID:          file.File.ProductID,
+			FileID:             file.File.ID,
+			Name:               file.File.Name,
+			Size:               file.File.Size,
+			URL:                h.signedURL(file.OrderItemID, file.File.ID, expiresAt),
+			ExpiresAt:          expiresAt,
+			DownloadsRemaining: max(maxDownloads-file.DownloadCount, 0),
+		})
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, links)
+}
+
+// handleDownload serves a purchased file through a signed download link.
+//	@Summary		Download a file
+//	@Description	Download a purchased file. The link must be unexpired and carry a valid signature, as returned by the order downloads endpoint. Every download counts against the limit of the order item.
+//	@Tags			downloads
+//	@Produce		octet-stream
+//	@Param			itemId		path		int					true	"Order item ID"
+//	@Param			fileId		path		int					true	"File ID"
+//	@Param			expires		query		int					true	"Expiry of the link as a Unix timestamp"
+//	@Param			signature	query		string				true	"Link signature"
+//	@Success		200			{file}		file				"file contents"
+//	@Failure		403			{object}	map[string]string	"invalid or expired link, or order not paid"
+//	@Failure		404			{object}	map[string]string	"file not found"
+//	@Failure		410			{object}	map[string]string	"download limit reached"
+//	@Failure		500			{object}	map[string]string	"internal server error"
+//	@Router			/downloads/{itemId}/{fileId} [get]
+func (h *Handler) handleDownload(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("itemId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, ErrFileNotFound)
+		return
+	}
+	fileID, err := strconv.Atoi(c.Param("fileId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, ErrFileNotFound)
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("invalid download link"))
+		return
+	}
+
+	// Check the signature before the expiry so an altered expiry is reported as invalid
+	expected := sign(itemID, fileID, expires)
+	if !hmac.Equal([]byte(c.Query("signature")), []byte(expected)) {
+		utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("invalid download link"))
+		return
+	}
+	if time.Now().Unix() > expires {
+		utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("download link has expired"))
+		return
+	}
+
+	file, err := h.store.GetDownloadableFile(itemID, fileID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// The order may have been cancelled since the link was issued
+	if !isPaid(file.OrderStatus) {
+		utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("downloads are available once the order is paid"))
+		return
+	}
+
+	path := filepath.Join(config.Envs.DIGITAL_FILES_DIR, file.File.Path)
+	if _, err := os.Stat(path); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"fileID": fileID,
+			"error":  err,
+		}).Error("Product file missing from storage")
+		utils.WriteError(c.Writer, http.StatusNotFound, ErrFileNotFound)
+		return
+	}
+
+	if err := h.store.RecordDownload(itemID, int(config.Envs.MAX_DOWNLOADS_PER_ITEM)); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the download
+	utils.Log.WithFields(logrus.Fields{
+		"orderID":     file.OrderID,
+		"orderItemID": itemID,
+		"fileID":      fileID,
+		"userID":      file.UserID,
+	}).Info("File downloaded")
+
+	c.FileAttachment(path, file.File.Name)
+}
+
+// signedURL builds a download link for a file of an order item that is valid until expiresAt
+func (h *Handler) signedURL(itemID, fileID int, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("%s/%d/%d?expires=%d&signature=%s", h.downloadPath, itemID, fileID, expires, sign(itemID, fileID, expires))
+}
+
+// sign computes the HMAC-SHA256 signature of a download link
+func sign(itemID, fileID int, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.Envs.DOWNLOAD_SECRET))
+	fmt.Fprintf(mac, "%d:%d:%d", itemID, fileID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isPaid reports whether an order in the given status has been paid for
+func isPaid(status string) bool {
+	return status == "successful" || status == "delivered"
+}
+
+// writeStoreError maps download store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrFileNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrDownloadLimitReached):
+		utils.WriteError(c.Writer, http.StatusGone, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,207 @@
+package download
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var (
+	ErrFileNotFound         = errors.New("file not found")
+	ErrDownloadLimitReached = errors.New("download limit reached")
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateProductFile stores a file attached to a product and returns its ID.
+func (s *Store) CreateProductFile(file types.ProductFile) (int, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO product_files (productId, name, path, size)
+		VALUES (?, ?, ?, ?)
+	`, file.ProductID, file.Name, file.Path, file.Size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create product file: %w", err)
+	}
+
+	fileID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return int(fileID), nil
+}
+
+// GetProductFiles retrieves the files attached to a product.
+func (s *Store) GetProductFiles(productID int) ([]types.ProductFile, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, productId, name, path, size, createdAt
+		FROM product_files
+		WHERE productId = ?
+		ORDER BY id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make([]types.ProductFile, 0)
+	for rows.Next() {
+		var file types.ProductFile
+		if err := rows.Scan(&file.ID, &file.ProductID, &file.Name, &file.Path, &file.Size, &file.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan product file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// GetProductFile retrieves a single file of a product.
+func (s *Store) GetProductFile(productID, fileID int) (*types.ProductFile, error) {
+	ctx := context.Background()
+
+	var file types.ProductFile
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, productId, name, path, size, createdAt
+		FROM product_files
+		WHERE id = ? AND productId = ?
+	`, fileID, productID).Scan(&file.ID, &file.ProductID, &file.Name, &file.Path, &file.Size, &file.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get product file: %w", err)
+	}
+
+	return &file, nil
+}
+
+// DeleteProductFile removes a file from a product.
+func (s *Store) DeleteProductFile(productID, fileID int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM product_files WHERE id = ? AND productId = ?", fileID, productID)
+	if err != nil {
+		return fmt.Errorf("failed to delete product file: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete product file: %w", err)
+	}
+	if n == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+// downloadableColumns selects a product file together with the order item it was bought with
+const downloadableColumns = `
+	f.id, f.productId, f.name, f.path, f.size, f.createdAt,
+	oi.id, o.id, o.userId, o.status, oi.downloadCount
+	FROM order_items oi
+	JOIN orders o ON o.id = oi.orderId
+	JOIN products p ON p.id = oi.productId
+	JOIN product_files f ON f.productId = oi.productId`
+
+func scanDownloadable(row interface{ Scan(...any) error }) (*types.DownloadableFile, error) {
+	var d types.DownloadableFile
+	if err := row.Scan(
+		&d.File.ID,
+		&d.File.ProductID,
+		&d.File.Name,
+		&d.File.Path,
+		&d.File.Size,
+		&d.File.CreatedAt,
+		&d.OrderItemID,
+		&d.OrderID,
+		&d.UserID,
+		&d.OrderStatus,
+		&d.DownloadCount,
+	); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// GetDownloadableFiles retrieves the files of the digital products in an order.
+func (s *Store) GetDownloadableFiles(orderID int) ([]types.DownloadableFile, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+downloadableColumns+`
+		WHERE oi.orderId = ? AND p.isDigital
+		ORDER BY oi.id, f.id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query downloadable files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make([]types.DownloadableFile, 0)
+	for rows.Next() {
+		file, err := scanDownloadable(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan downloadable file: %w", err)
+		}
+		files = append(files, *file)
+	}
+
+	return files, rows.Err()
+}
+
+// GetDownloadableFile retrieves a file bought with an order item.
+func (s *Store) GetDownloadableFile(orderItemID, fileID int) (*types.DownloadableFile, error) {
+	ctx := context.Background()
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+downloadableColumns+`
+		WHERE oi.id = ? AND f.id = ? AND p.isDigital
+	`, orderItemID, fileID)
+	file, err := scanDownloadable(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get downloadable file: %w", err)
+	}
+
+	return file, nil
+}
+
+// RecordDownload counts a download against an order item. The check and the increment
+// happen in one statement, so concurrent downloads can't exceed maxDownloads.
+func (s *Store) RecordDownload(orderItemID, maxDownloads int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE order_items SET downloadCount = downloadCount + 1
+		WHERE id = ? AND downloadCount < ?
+	`, orderItemID, maxDownloads)
+	if err != nil {
+		return fmt.Errorf("failed to record download: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to record download: %w", err)
+	}
+	if n == 0 {
+		return ErrDownloadLimitReached
+	}
+
+	return nil
+}
@@ -1,27 +1,36 @@
 package order
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
 	"github.com/youngprinnce/go-ecom/middleware"
 	"github.com/youngprinnce/go-ecom/types"
 	"github.com/youngprinnce/go-ecom/utils"
 )
 
+// ErrOutOfStock is returned when a product doesn't have enough stock for an order
+var ErrOutOfStock = errors.New("out of stock")
+
 type Handler struct {
-	productStore types.ProductStore
-	orderStore   types.OrderStore
-	userStore    types.UserStore
+	productStore    types.ProductStore
+	orderStore      types.OrderStore
+	userStore       types.UserStore
+	restockNotifier types.RestockNotifier
 }
 
-func NewHandler(productStore types.ProductStore, orderStore types.OrderStore, userStore types.UserStore) *Handler {
+func NewHandler(productStore types.ProductStore, orderStore types.OrderStore, userStore types.UserStore, restockNotifier types.RestockNotifier) *Handler {
 	return &Handler{
-		productStore: productStore,
-		orderStore:   orderStore,
-		userStore:    userStore,
+		productStore:    productStore,
+		orderStore:      orderStore,
+		userStore:       userStore,
+		restockNotifier: restockNotifier,
 	}
 }
 
@@ -35,10 +44,15 @@ func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 	orderRouter.POST("", h.handleCreateOrder)
 	orderRouter.DELETE("/:id", h.handleCancelOrder)
 
-	// Admin-only route
+	// Staff-only route
 	adminRouter := orderRouter.Group("/:id/status")
-	adminRouter.Use(middleware.AdminOnly())
+	adminRouter.Use(middleware.RequirePermission(types.PermissionOrdersWrite))
 	adminRouter.PUT("", h.handleUpdateOrderStatus)
+
+	// Staff can look up the orders of any user
+	userOrderRouter := router.Group("/users/:id/orders")
+	userOrderRouter.Use(middleware.JWTAuth(), middleware.RequirePermission(types.PermissionOrdersRead))
+	userOrderRouter.GET("", h.handleGetUserOrders)
 }
 
 // handleCreateOrder handles the checkout process for the cart.
@@ -52,6 +66,8 @@ func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 //	@Success		200		{object}	map[string]interface{}		"orderID and totalPrice"
 //	@Failure		400		{object}	map[string]string			"invalid request payload"
 //	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		403		{object}	map[string]string			"email not verified"
+//	@Failure		409		{object}	map[string]string			"product out of stock"
 //	@Failure		500		{object}	map[string]string			"internal server error"
 //	@Router			/orders [post]
 func (h *Handler) handleCreateOrder(c *gin.Context) {
@@ -62,6 +78,19 @@ func (h *Handler) handleCreateOrder(c *gin.Context) {
 		return
 	}
 
+	// Accounts may have to verify their email before ordering
+	if config.Envs.REQUIRE_VERIFIED_EMAIL_FOR_CHECKOUT {
+		u, err := h.userStore.GetUserByID(userID.(int))
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+			return
+		}
+		if u.EmailVerifiedAt == nil {
+			utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("verify your email address before placing an order, a new link can be sent from /users/verify-email/resend"))
+			return
+		}
+	}
+
 	// Parse the request payload
 	var payload types.CartCheckoutPayload
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -87,6 +116,10 @@ func (h *Handler) handleCreateOrder(c *gin.Context) {
 
 	// Create the order
 	orderID, total, err := h.createOrder(products, payload.Items, userID.(int))
+	if errors.Is(err, ErrOutOfStock) {
+		utils.WriteError(c.Writer, http.StatusConflict, fmt.Errorf("%v, subscribe at /subscriptions to be notified when it is back", err))
+		return
+	}
 	if err != nil {
 		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
 		return
@@ -128,9 +161,42 @@ func (h *Handler) handleGetOrders(c *gin.Context) {
 	utils.WriteJSON(c.Writer, http.StatusOK, orders)
 }
 
+// handleGetUserOrders retrieves the orders of a user for admins.
+//	@Summary		Get the orders of a user
+//	@Description	Get all orders of a user (requires orders:read)
+//	@Tags			orders
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"User ID"
+//	@Success		200	{array}		types.Order			"list of orders"
+//	@Failure		400	{object}	map[string]string	"invalid user ID"
+//	@Failure		404	{object}	map[string]string	"user not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/{id}/orders [get]
+func (h *Handler) handleGetUserOrders(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	if _, err := h.userStore.GetUserByID(userID); err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("user not found"))
+		return
+	}
+
+	orders, err := h.orderStore.GetOrdersByUserID(userID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, orders)
+}
+
 // handleUpdateOrderStatus updates the status of an order.
 //	@Summary		Update order status
-//	@Description	Update the status of an order (admin only)
+//	@Description	Update the status of an order (requires orders:write)
 //	@Tags			orders
 //	@Accept			json
 //	@Produce		json
@@ -158,6 +224,12 @@ func (h *Handler) handleUpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
 	// Update the order status
 	if err := h.orderStore.UpdateOrderStatus(orderID, payload.Status); err != nil {
 		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
@@ -178,6 +250,7 @@ func (h *Handler) handleUpdateOrderStatus(c *gin.Context) {
 //	@Success		204	"no content"
 //	@Failure		400	{object}	map[string]string	"invalid order ID or order not pending"
 //	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		404	{object}	map[string]string	"order not found"
 //	@Failure		500	{object}	map[string]string	"internal server error"
 //	@Router			/orders/{id} [delete]
 func (h *Handler) handleCancelOrder(c *gin.Context) {
@@ -195,9 +268,10 @@ func (h *Handler) handleCancelOrder(c *gin.Context) {
 		return
 	}
 
+	// Orders of other users are reported as missing, so their IDs can't be probed
 	order, err := h.orderStore.GetOrderByID(orderID)
-	if err != nil {
-		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("order not found"))
+	if err != nil || order.UserID != userID.(int) {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("order not found"))
 		return
 	}
 
@@ -206,41 +280,32 @@ func (h *Handler) handleCancelOrder(c *gin.Context) {
 		return
 	}
 
-	// restore product quantities
 	orderItems, err := h.orderStore.GetOrderItemsByOrderID(orderID)
 	if err != nil {
 		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
 		return
 	}
 
-	// Create a map of products for quick lookup
-	productMap := make(map[int]*types.Product)
-	for _, item := range orderItems {
-		product, err := h.productStore.GetProductByID(item.ProductID)
-		if err != nil {
-			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
-			return
-		}
-		productMap[product.ID] = product
+	// The stock of bundles follows from their components, so note which are sold out now
+	soldOut, err := h.soldOutBundles(orderItems)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
 	}
 
-	// Update product quantities
-	for _, item := range orderItems {
-		product := productMap[item.ProductID]
-		product.Quantity += item.Quantity
-
-		if err := h.productStore.UpdateProduct(*product); err != nil {
-			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
-			return
-		}
+	// Cancel the order, putting its stock back, bundles into their components' stock
+	restocked, err := h.orderStore.CancelOrder(orderID, userID.(int))
+	if errors.Is(err, ErrOrderNotPending) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
 	}
-
-	// Cancel the order
-	if err := h.orderStore.CancelOrder(orderID, userID.(int)); err != nil {
+	if err != nil {
 		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
 		return
 	}
 
+	h.notifyRestocked(restocked, soldOut)
+
 	// Return success response
 	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "order cancelled"})
 }
@@ -271,12 +336,26 @@ func (h *Handler) createOrder(products []types.Product, items []types.CartChecko
 	// Calculate the total price
 	totalPrice := calculateTotalPrice(productMap, items)
 
-	// Update product quantities
-	for _, item := range items {
-		product := productMap[item.ProductID]
-		product.Quantity -= item.Quantity
-
-		if err := h.productStore.UpdateProduct(product); err != nil {
+	// Bundles are taken out of their components' stock
+	components := make(map[int][]types.BundleComponent)
+	for _, product := range products {
+		if product.Kind != types.ProductKindBundle {
+			continue
+		}
+		bundleComponents, err := h.productStore.GetBundleComponents(product.ID)
+		if err != nil {
+			return 0, 0, err
+		}
+		components[product.ID] = bundleComponents
+	}
+	stock := stockChanges(items, components)
+
+	// Update product quantities, putting back what was already taken if an item fails
+	for i, change := range stock {
+		if _, err := h.adjustStock(change.ProductID, -change.Quantity); err != nil {
+			for _, taken := range stock[:i] {
+				h.adjustStock(taken.ProductID, taken.Quantity)
+			}
 			return 0, 0, fmt.Errorf("failed to update product: %w", err)
 		}
 	}
@@ -296,10 +375,11 @@ func (h *Handler) createOrder(products []types.Product, items []types.CartChecko
 	for _, item := range items {
 		product := productMap[item.ProductID]
 		if err := h.orderStore.CreateOrderItem(types.OrderItem{
-			OrderID:   orderID,
-			ProductID: product.ID,
-			Quantity:  item.Quantity,
-			Price:     product.Price,
+			OrderID:    orderID,
+			ProductID:  product.ID,
+			Quantity:   item.Quantity,
+			Price:      product.Price,
+			Components: components[product.ID],
 		}); err != nil {
 			return 0, 0, fmt.Errorf("failed to create order item: %w", err)
 		}
@@ -308,6 +388,118 @@ func (h *Handler) createOrder(products []types.Product, items []types.CartChecko
 	return orderID, totalPrice, nil
 }
 
+// stockChanges works out how much of each product a cart takes out of stock, in product ID order.
+// Bundles count towards their components, so a bundle and one of its components bought together
+// are checked against the component's stock as a whole.
+func stockChanges(items []types.CartCheckoutItem, components map[int][]types.BundleComponent) []types.CartCheckoutItem {
+	quantities := make(map[int]int)
+	for _, item := range items {
+		bundleComponents, ok := components[item.ProductID]
+		if !ok {
+			quantities[item.ProductID] += item.Quantity
+			continue
+		}
+		for _, component := range bundleComponents {
+			quantities[component.ProductID] += component.Quantity * item.Quantity
+		}
+	}
+
+	changes := make([]types.CartCheckoutItem, 0, len(quantities))
+	for productID, quantity := range quantities {
+		changes = append(changes, types.CartCheckoutItem{ProductID: productID, Quantity: quantity})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ProductID < changes[j].ProductID })
+
+	return changes
+}
+
+// maxStockRetries bounds how often adjustStock re-reads a product that keeps changing under it
+const maxStockRetries = 5
+
+// adjustStock changes the quantity of a product by delta. Stock is read, changed and written
+// back with the product version, so when an admin edit or another checkout updates the product
+// in between, the write is rejected and the whole read-modify-write is retried.
+func (h *Handler) adjustStock(productID int, delta int) (*types.Product, error) {
+	for attempt := 0; attempt < maxStockRetries; attempt++ {
+		product, err := h.productStore.GetProductByID(productID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Digital products have no stock to keep track of
+		if product.IsDigital {
+			return product, nil
+		}
+
+		if product.Quantity+delta < 0 {
+			return nil, fmt.Errorf("product %d is %w", productID, ErrOutOfStock)
+		}
+		product.Quantity += delta
+
+		err = h.productStore.UpdateProduct(*product)
+		if errors.Is(err, types.ErrProductVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		product.Version++
+		return product, nil
+	}
+
+	return nil, fmt.Errorf("product %d is being updated, please try again", productID)
+}
+
+// soldOutBundles lists the bundles that contain products of an order and are sold out.
+func (h *Handler) soldOutBundles(orderItems []types.OrderItem) ([]int, error) {
+	seen := make(map[int]bool)
+	soldOut := make([]int, 0)
+	for _, item := range orderItems {
+		productIDs := []int{item.ProductID}
+		if len(item.Components) > 0 {
+			productIDs = productIDs[:0]
+			for _, component := range item.Components {
+				productIDs = append(productIDs, component.ProductID)
+			}
+		}
+
+		for _, productID := range productIDs {
+			bundles, err := h.productStore.GetBundlesContaining(productID)
+			if err != nil {
+				return nil, err
+			}
+			for _, bundle := range bundles {
+				if !seen[bundle.ID] && bundle.Quantity <= 0 {
+					soldOut = append(soldOut, bundle.ID)
+				}
+				seen[bundle.ID] = true
+			}
+		}
+	}
+	return soldOut, nil
+}
+
+// notifyRestocked tells the restock notifier about the products a cancelled order brought back
+// in stock, and about the bundles that were sold out and can be bought again now. It is only
+// called once the cancellation has been committed. The stock has been put back already, so a
+// failed lookup only costs the notification.
+func (h *Handler) notifyRestocked(productIDs []int, soldOutBundles []int) {
+	for _, productID := range append(productIDs, soldOutBundles...) {
+		product, err := h.productStore.GetProductByID(productID)
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"productID": productID,
+				"error":     err,
+			}).Error("Failed to check whether a product is back in stock")
+			continue
+		}
+		if product.Quantity > 0 {
+			go h.restockNotifier.NotifyRestocked(*product)
+		}
+	}
+}
+
 // checkIfProductIsInStock ensures all products in the cart are in stock.
 func checkIfProductIsInStock(productMap map[int]types.Product, cartItems []types.CartCheckoutItem) error {
 	for _, item := range cartItems {
@@ -315,8 +507,8 @@ func checkIfProductIsInStock(productMap map[int]types.Product, cartItems []types
 		if !ok {
 			return fmt.Errorf("product %d not found", item.ProductID)
 		}
-		if product.Quantity < item.Quantity {
-			return fmt.Errorf("product %d is out of stock", product.ID)
+		if !product.IsDigital && product.Quantity < item.Quantity {
+			return fmt.Errorf("product %d is %w", product.ID, ErrOutOfStock)
 		}
 	}
 	return nil
@@ -3,11 +3,17 @@ package order
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/youngprinnce/go-ecom/types"
 )
 
+// ErrOrderNotPending is returned when cancelling an order that isn't pending anymore
+var ErrOrderNotPending = errors.New("order is not pending, can't cancel")
+
 type Store struct {
 	db *sql.DB
 }
@@ -42,11 +48,20 @@ func (s *Store) CreateOrder(order types.Order) (int, error) {
 func (s *Store) CreateOrderItem(orderItem types.OrderItem) error {
 	ctx := context.Background()
 
+	// Bundles keep a snapshot of their components, so cancelling restocks what was actually sold
+	var components []byte
+	if len(orderItem.Components) > 0 {
+		var err error
+		if components, err = json.Marshal(orderItem.Components); err != nil {
+			return fmt.Errorf("failed to encode bundle components: %w", err)
+		}
+	}
+
 	// Insert the order item into the database
 	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO order_items (orderId, productId, quantity, price)
-		VALUES (?, ?, ?, ?)
-	`, orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price)
+		INSERT INTO order_items (orderId, productId, quantity, price, components)
+		VALUES (?, ?, ?, ?, ?)
+	`, orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price, components)
 	if err != nil {
 		return fmt.Errorf("failed to create order item: %w", err)
 	}
@@ -56,11 +71,18 @@ func (s *Store) CreateOrderItem(orderItem types.OrderItem) error {
 
 // GetOrderItemByOrderID retrieves all order items for a specific order.
 func (s *Store) GetOrderItemsByOrderID(orderID int) ([]types.OrderItem, error) {
-	ctx := context.Background()
+	return queryOrderItems(context.Background(), s.db, orderID)
+}
 
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryOrderItems(ctx context.Context, q querier, orderID int) ([]types.OrderItem, error) {
 	// Query the database for order item by order ID
-	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, orderId, productId, quantity, price
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, orderId, productId, quantity, price, components
 		FROM order_items
 		WHERE orderId = ?
 	`, orderID)
@@ -73,22 +95,28 @@ func (s *Store) GetOrderItemsByOrderID(orderID int) ([]types.OrderItem, error) {
 	orderItems := make([]types.OrderItem, 0)
 	for rows.Next() {
 		var orderItem types.OrderItem
+		var components []byte
 		if err := rows.Scan(
 			&orderItem.ID,
 			&orderItem.OrderID,
 			&orderItem.ProductID,
 			&orderItem.Quantity,
 			&orderItem.Price,
+			&components,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan order item: %w", err)
 		}
+		if components != nil {
+			if err := json.Unmarshal(components, &orderItem.Components); err != nil {
+				return nil, fmt.Errorf("failed to decode bundle components: %w", err)
+			}
+		}
 		orderItems = append(orderItems, orderItem)
 	}
 
-	return orderItems, nil
+	return orderItems, rows.Err()
 }
 
-
 // GetOrdersByUserID retrieves all orders for a specific user.
 func (s *Store) GetOrdersByUserID(userID int) ([]types.Order, error) {
 	ctx := context.Background()
@@ -171,19 +199,84 @@ func (s *Store) UpdateOrderStatus(orderID int, status string) error {
 	return nil
 }
 
-// CancelOrder cancels an order if it is still in the "pending" status.
-func (s *Store) CancelOrder(orderID int, userID int) error {
+// CancelOrder cancels an order of a user if it is still in the "pending" status and puts what
+// it took out of stock back, bundles into their components, in the same transaction. It returns
+// the IDs of the products it brought back in stock, whose quantity went from zero to above zero.
+func (s *Store) CancelOrder(orderID int, userID int) ([]int, error) {
 	ctx := context.Background()
 
-	// Cancel the order in the database
-	_, err := s.db.ExecContext(ctx, `
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel order: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Only one request gets to cancel the order, so its stock is only put back once
+	res, err := tx.ExecContext(ctx, `
 		UPDATE orders
 		SET status = 'cancelled'
 		WHERE id = ? AND userId = ? AND status = 'pending'
 	`, orderID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to cancel order: %w", err)
+		return nil, fmt.Errorf("failed to cancel order: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to cancel order: %w", err)
+	} else if affected != 1 {
+		return nil, ErrOrderNotPending
 	}
 
-	return nil
+	orderItems, err := queryOrderItems(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	restock := make(map[int]int)
+	for _, item := range orderItems {
+		if len(item.Components) == 0 {
+			restock[item.ProductID] += item.Quantity
+			continue
+		}
+		for _, component := range item.Components {
+			restock[component.ProductID] += component.Quantity * item.Quantity
+		}
+	}
+
+	// Products are locked in ID order, like checkout does, so concurrent cancellations can't deadlock
+	productIDs := make([]int, 0, len(restock))
+	for productID := range restock {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Ints(productIDs)
+
+	restocked := make([]int, 0)
+	for _, productID := range productIDs {
+		var quantity int
+		var isDigital bool
+		err := tx.QueryRowContext(ctx, "SELECT quantity, isDigital FROM products WHERE id = ? FOR UPDATE", productID).Scan(&quantity, &isDigital)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restock product %d: %w", productID, err)
+		}
+
+		// Digital products have no stock to keep track of
+		if isDigital {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE products SET quantity = quantity + ?, version = version + 1 WHERE id = ?", restock[productID], productID); err != nil {
+			return nil, fmt.Errorf("failed to restock product %d: %w", productID, err)
+		}
+		if quantity <= 0 && quantity+restock[productID] > 0 {
+			restocked = append(restocked, productID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to cancel order: %w", err)
+	}
+
+	return restocked, nil
 }
@@ -0,0 +1,154 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// handleGetBundleComponents retrieves the components of a bundle.
+//	@Summary		Get bundle components
+//	@Description	Get the products a bundle consists of (requires products:read)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int						true	"Product ID"
+//	@Success		200	{array}		types.BundleComponent	"bundle components"
+//	@Failure		400	{object}	map[string]string		"invalid product ID"
+//	@Failure		500	{object}	map[string]string		"internal server error"
+//	@Router			/products/{id}/components [get]
+func (h *Handler) handleGetBundleComponents(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	components, err := h.store.GetBundleComponents(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, components)
+}
+
+// handleSetBundleComponents turns a product into a bundle.
+//	@Summary		Set bundle components
+//	@Description	Make a product a bundle of other products, replacing its current components (requires products:write). The bundle keeps its own price; its quantity is computed from the stock of its components, which are taken out of stock when the bundle is ordered.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int									true	"Product ID"
+//	@Param			payload	body		types.SetBundleComponentsPayload	true	"Bundle components"
+//	@Success		200		{object}	types.Product						"updated bundle"
+//	@Failure		400		{object}	map[string]string					"invalid product ID, payload or components"
+//	@Failure		404		{object}	map[string]string					"product not found"
+//	@Failure		500		{object}	map[string]string					"internal server error"
+//	@Router			/products/{id}/components [put]
+func (h *Handler) handleSetBundleComponents(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	var payload types.SetBundleComponentsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	if err := h.store.SetBundleComponents(productID, payload.Components); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	product, err := h.getProduct(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the new composition
+	utils.Log.WithFields(logrus.Fields{
+		"productID":  productID,
+		"components": product.Components,
+	}).Info("Bundle components updated")
+
+	c.Header("ETag", etag(product))
+	utils.WriteJSON(c.Writer, http.StatusOK, product)
+}
+
+// handleClearBundleComponents turns a bundle back into a simple product.
+//	@Summary		Remove bundle components
+//	@Description	Remove all components of a bundle so it becomes a simple product with its own stock again (requires products:write)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path	int	true	"Product ID"
+//	@Success		204	"no content"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		404	{object}	map[string]string	"product not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/components [delete]
+func (h *Handler) handleClearBundleComponents(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	if err := h.store.ClearBundleComponents(productID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the change
+	utils.Log.WithFields(logrus.Fields{
+		"productID": productID,
+	}).Info("Bundle components removed")
+
+	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
+}
+
+// getProduct retrieves a product along with its components if it is a bundle
+func (h *Handler) getProduct(productID int) (*types.Product, error) {
+	product, err := h.store.GetProductByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.loadComponents(product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// loadComponents fills in the components of a bundle
+func (h *Handler) loadComponents(product *types.Product) error {
+	if product.Kind != types.ProductKindBundle {
+		return nil
+	}
+
+	components, err := h.store.GetBundleComponents(product.ID)
+	if err != nil {
+		return err
+	}
+	product.Components = components
+
+	return nil
+}
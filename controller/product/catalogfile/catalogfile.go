@@ -0,0 +1,261 @@
+// Package catalogfile reads and writes the CSV and JSON files products are imported from and
+// exported to.
+package catalogfile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// csvColumns is the header used for CSV exports and expected on CSV imports
+var csvColumns = []string{"sku", "name", "description", "image", "price", "quantity", "status", "publishAt"}
+
+// ParseCSV reads import rows from a CSV file with a header line.
+// Columns are matched by name, so their order doesn't matter. Rows that can't be
+// parsed are reported as row errors and left out of the result.
+func ParseCSV(r io.Reader) ([]types.ProductImportRow, []types.ProductImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read CSV header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"sku", "name", "price", "quantity"} {
+		if _, ok := index[name]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %q column", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := index[strings.ToLower(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]types.ProductImportRow, 0)
+	rowErrors := make([]types.ProductImportRowError, 0)
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, types.ProductImportRowError{Row: n, Error: err.Error()})
+			continue
+		}
+
+		row := types.ProductImportRow{
+			Row:         n,
+			SKU:         field(record, "sku"),
+			Name:        field(record, "name"),
+			Description: field(record, "description"),
+			Image:       field(record, "image"),
+			Status:      field(record, "status"),
+		}
+
+		if row.Price, err = strconv.ParseFloat(field(record, "price"), 64); err != nil {
+			rowErrors = append(rowErrors, types.ProductImportRowError{Row: n, SKU: row.SKU, Error: "invalid price"})
+			continue
+		}
+		if row.Quantity, err = strconv.Atoi(field(record, "quantity")); err != nil {
+			rowErrors = append(rowErrors, types.ProductImportRowError{Row: n, SKU: row.SKU, Error: "invalid quantity"})
+			continue
+		}
+		if raw := field(record, "publishAt"); raw != "" {
+			publishAt, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				rowErrors = append(rowErrors, types.ProductImportRowError{Row: n, SKU: row.SKU, Error: "invalid publishAt, use RFC 3339"})
+				continue
+			}
+			row.PublishAt = &publishAt
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+// ParseJSON reads import rows from a JSON array of products
+func ParseJSON(r io.Reader) ([]types.ProductImportRow, error) {
+	var rows []types.ProductImportRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("could not decode JSON: %w", err)
+	}
+
+	for i := range rows {
+		rows[i].Row = i + 1
+	}
+
+	return rows, nil
+}
+
+// Import validates every row with utils.Validate and upserts the valid ones by SKU.
+// rowErrors carries problems found while parsing and is included in the report. On a dry run
+// nothing is written, but the report still says how many products would be created or updated.
+func Import(store types.ProductStore, rows []types.ProductImportRow, rowErrors []types.ProductImportRowError, dryRun bool) (*types.ProductImportReport, error) {
+	report := &types.ProductImportReport{
+		DryRun: dryRun,
+		Total:  len(rows) + len(rowErrors),
+		Errors: rowErrors,
+	}
+
+	valid := make([]types.ProductImportRow, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if err := utils.Validate.Struct(row); err != nil {
+			report.Errors = append(report.Errors, types.ProductImportRowError{Row: row.Row, SKU: row.SKU, Error: fmt.Sprintf("invalid row: %v", err)})
+			continue
+		}
+		if row.Status == types.ProductStatusScheduled && !row.PublishAt.After(time.Now()) {
+			report.Errors = append(report.Errors, types.ProductImportRowError{Row: row.Row, SKU: row.SKU, Error: "publishAt must be in the future"})
+			continue
+		}
+		if first, ok := seen[row.SKU]; ok {
+			report.Errors = append(report.Errors, types.ProductImportRowError{Row: row.Row, SKU: row.SKU, Error: fmt.Sprintf("duplicate SKU, first seen on row %d", first)})
+			continue
+		}
+		seen[row.SKU] = row.Row
+		valid = append(valid, row)
+	}
+
+	skus := make([]string, len(valid))
+	for i, row := range valid {
+		skus[i] = row.SKU
+	}
+	existing, err := store.GetProductsBySKUs(skus)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stock of bundles comes from their components, so rows can't overwrite it
+	bundles := make(map[string]bool)
+	for _, p := range existing {
+		if p.Kind == types.ProductKindBundle {
+			bundles[p.SKU] = true
+		}
+	}
+	if len(bundles) > 0 {
+		rows := valid
+		valid = make([]types.ProductImportRow, 0, len(rows))
+		for _, row := range rows {
+			if bundles[row.SKU] {
+				report.Errors = append(report.Errors, types.ProductImportRowError{Row: row.Row, SKU: row.SKU, Error: "product is a bundle, bundles can't be imported"})
+				continue
+			}
+			valid = append(valid, row)
+		}
+	}
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+
+	if dryRun {
+		report.Updated = len(existing) - len(bundles)
+		report.Created = len(valid) - report.Updated
+
+		return report, nil
+	}
+
+	if len(valid) == 0 {
+		return report, nil
+	}
+
+	created, updated, err := store.UpsertProducts(valid)
+	if err != nil {
+		return nil, err
+	}
+	report.Created = created
+	report.Updated = updated
+
+	return report, nil
+}
+
+// ExportCSV writes products using the same columns the CSV import expects. Products the
+// import can't take back are left out, see importable.
+func ExportCSV(w io.Writer, products []*types.Product) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, p := range importable(products) {
+		if err := writer.Write([]string{
+			p.SKU,
+			p.Name,
+			p.Description,
+			p.Image,
+			strconv.FormatFloat(p.Price, 'f', 2, 64),
+			strconv.Itoa(p.Quantity),
+			p.Status,
+			formatPublishAt(p),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportJSON writes products as a JSON array that the JSON import accepts. Products the
+// import can't take back are left out, see importable.
+func ExportJSON(w io.Writer, products []*types.Product) error {
+	products = importable(products)
+	rows := make([]types.ProductImportRow, len(products))
+	for i, p := range products {
+		rows[i] = types.ProductImportRow{
+			SKU:         p.SKU,
+			Name:        p.Name,
+			Description: p.Description,
+			Image:       p.Image,
+			Price:       p.Price,
+			Quantity:    p.Quantity,
+			Status:      p.Status,
+		}
+		if p.Status == types.ProductStatusScheduled {
+			rows[i].PublishAt = p.PublishAt
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+// importable leaves out the products an import can't recreate: those without a SKU, which
+// imports are matched by, and bundles, whose stock comes from their components.
+func importable(products []*types.Product) []*types.Product {
+	kept := make([]*types.Product, 0, len(products))
+	for _, p := range products {
+		if p.SKU == "" || p.Kind == types.ProductKindBundle {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// formatPublishAt formats the publishAt of scheduled products for CSV exports. The column is
+// left empty for other statuses, which set publishAt themselves when imported.
+func formatPublishAt(p *types.Product) string {
+	if p.Status != types.ProductStatusScheduled || p.PublishAt == nil {
+		return ""
+	}
+	return p.PublishAt.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,149 @@
+package catalogfile
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+// fakeStore knows the products given to it and records the rows it is asked to upsert.
+// Calling any other method of types.ProductStore panics.
+type fakeStore struct {
+	types.ProductStore
+	products []*types.Product
+	upserted []types.ProductImportRow
+}
+
+func (s *fakeStore) GetProductsBySKUs(skus []string) ([]types.Product, error) {
+	found := make([]types.Product, 0)
+	for _, p := range s.products {
+		for _, sku := range skus {
+			if p.SKU != "" && p.SKU == sku {
+				found = append(found, *p)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (s *fakeStore) UpsertProducts(rows []types.ProductImportRow) (int, int, error) {
+	s.upserted = append(s.upserted, rows...)
+	existing, _ := s.GetProductsBySKUs(skusOf(rows))
+	return len(rows) - len(existing), len(existing), nil
+}
+
+func skusOf(rows []types.ProductImportRow) []string {
+	skus := make([]string, len(rows))
+	for i, row := range rows {
+		skus[i] = row.SKU
+	}
+	return skus
+}
+
+// catalog returns products of every kind an export can come across
+func catalog() []*types.Product {
+	publishAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	return []*types.Product{
+		{ID: 1, SKU: "TSHIRT-M", Kind: types.ProductKindSimple, Name: "T-Shirt, \"M\"", Description: "Cotton\nshirt", Image: "tshirt.png", Price: 19.99, Quantity: 5, Status: types.ProductStatusPublished},
+		{ID: 2, SKU: "MUG", Kind: types.ProductKindSimple, Name: "Mug", Price: 8.5, Quantity: 0, Status: types.ProductStatusScheduled, PublishAt: &publishAt},
+		{ID: 3, Kind: types.ProductKindSimple, Name: "Sticker without a SKU", Price: 1, Quantity: 100, Status: types.ProductStatusDraft},
+		{ID: 4, SKU: "KIT", Kind: types.ProductKindBundle, Name: "Starter kit", Price: 25, Quantity: 3, Status: types.ProductStatusPublished},
+	}
+}
+
+// importRows is what importing the export of catalog should read back
+func importRows(products []*types.Product) []types.ProductImportRow {
+	return []types.ProductImportRow{
+		{Row: 1, SKU: "TSHIRT-M", Name: "T-Shirt, \"M\"", Description: "Cotton\nshirt", Image: "tshirt.png", Price: 19.99, Quantity: 5, Status: types.ProductStatusPublished},
+		{Row: 2, SKU: "MUG", Name: "Mug", Price: 8.5, Quantity: 0, Status: types.ProductStatusScheduled, PublishAt: products[1].PublishAt},
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		export func(*bytes.Buffer, []*types.Product) error
+		parse  func(*bytes.Buffer) ([]types.ProductImportRow, []types.ProductImportRowError, error)
+	}{
+		{
+			name:   "csv",
+			export: func(b *bytes.Buffer, p []*types.Product) error { return ExportCSV(b, p) },
+			parse:  func(b *bytes.Buffer) ([]types.ProductImportRow, []types.ProductImportRowError, error) { return ParseCSV(b) },
+		},
+		{
+			name:   "json",
+			export: func(b *bytes.Buffer, p []*types.Product) error { return ExportJSON(b, p) },
+			parse: func(b *bytes.Buffer) ([]types.ProductImportRow, []types.ProductImportRowError, error) {
+				rows, err := ParseJSON(b)
+				return rows, nil, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := catalog()
+
+			var file bytes.Buffer
+			if err := tt.export(&file, products); err != nil {
+				t.Fatalf("export failed: %v", err)
+			}
+
+			rows, rowErrors, err := tt.parse(&file)
+			if err != nil {
+				t.Fatalf("parsing the export failed: %v", err)
+			}
+			if len(rowErrors) > 0 {
+				t.Fatalf("parsing the export reported errors: %+v", rowErrors)
+			}
+			if want := importRows(products); !reflect.DeepEqual(rows, want) {
+				t.Fatalf("parsed rows = %+v, want %+v", rows, want)
+			}
+
+			store := &fakeStore{products: products}
+			report, err := Import(store, rows, rowErrors, false)
+			if err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+			if len(report.Errors) > 0 {
+				t.Errorf("import reported errors: %+v", report.Errors)
+			}
+			if report.Total != 2 || report.Updated != 2 || report.Created != 0 {
+				t.Errorf("report = %+v, want 2 products updated", report)
+			}
+			if !reflect.DeepEqual(store.upserted, rows) {
+				t.Errorf("upserted %+v, want %+v", store.upserted, rows)
+			}
+		})
+	}
+}
+
+func TestImportRejectsBundles(t *testing.T) {
+	store := &fakeStore{products: catalog()}
+	rows := []types.ProductImportRow{
+		{Row: 1, SKU: "KIT", Name: "Starter kit", Price: 25, Quantity: 10},
+		{Row: 2, SKU: "TSHIRT-M", Name: "T-Shirt", Price: 19.99, Quantity: 5},
+		{Row: 3, SKU: "CAP", Name: "Cap", Price: 12, Quantity: 7},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		store.upserted = nil
+
+		report, err := Import(store, rows, nil, dryRun)
+		if err != nil {
+			t.Fatalf("import failed: %v", err)
+		}
+		if len(report.Errors) != 1 || report.Errors[0].SKU != "KIT" {
+			t.Errorf("dry run %v: errors = %+v, want the KIT row rejected", dryRun, report.Errors)
+		}
+		if report.Created != 1 || report.Updated != 1 {
+			t.Errorf("dry run %v: report = %+v, want 1 product created and 1 updated", dryRun, report)
+		}
+		if !dryRun && !reflect.DeepEqual(skusOf(store.upserted), []string{"TSHIRT-M", "CAP"}) {
+			t.Errorf("upserted %v, want TSHIRT-M and CAP", skusOf(store.upserted))
+		}
+	}
+}
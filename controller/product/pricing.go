@@ -0,0 +1,182 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// handleGetPriceHistory retrieves the price changes of a product.
+//	@Summary		Get price history
+//	@Description	Get every price change of a product, newest first (requires products:read)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int							true	"Product ID"
+//	@Success		200	{array}		types.PriceHistoryEntry		"price history"
+//	@Failure		400	{object}	map[string]string			"invalid product ID"
+//	@Failure		500	{object}	map[string]string			"internal server error"
+//	@Router			/products/{id}/price-history [get]
+func (h *Handler) handleGetPriceHistory(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	history, err := h.store.GetPriceHistory(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, history)
+}
+
+// handleGetPriceSchedules retrieves the price schedules of a product.
+//	@Summary		Get price schedules
+//	@Description	Get the scheduled, running and past price changes of a product (requires products:read)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Product ID"
+//	@Success		200	{array}		types.PriceSchedule	"price schedules"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/price-schedules [get]
+func (h *Handler) handleGetPriceSchedules(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	schedules, err := h.store.GetPriceSchedules(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, schedules)
+}
+
+// handleCreatePriceSchedule schedules a future price change or sale.
+//	@Summary		Schedule a price change
+//	@Description	Schedule a new regular price, or a sale price with a start and end time (requires products:write)
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int									true	"Product ID"
+//	@Param			payload	body		types.CreatePriceSchedulePayload	true	"Price schedule payload"
+//	@Success		201		{object}	types.PriceSchedule					"created price schedule"
+//	@Failure		400		{object}	map[string]string					"invalid product ID or payload"
+//	@Failure		404		{object}	map[string]string					"product not found"
+//	@Failure		409		{object}	map[string]string					"overlapping sale"
+//	@Failure		500		{object}	map[string]string					"internal server error"
+//	@Router			/products/{id}/price-schedules [post]
+func (h *Handler) handleCreatePriceSchedule(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	var payload types.CreatePriceSchedulePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	switch payload.Type {
+	case "sale":
+		if payload.EndsAt == nil || !payload.EndsAt.After(payload.StartsAt) {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("a sale needs an endsAt after startsAt"))
+			return
+		}
+	case "price":
+		if payload.EndsAt != nil {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("a price change can't have an endsAt, schedule a sale instead"))
+			return
+		}
+	}
+
+	schedule := types.PriceSchedule{
+		ProductID: productID,
+		Type:      payload.Type,
+		Price:     payload.Price,
+		StartsAt:  payload.StartsAt,
+		EndsAt:    payload.EndsAt,
+		Status:    "scheduled",
+	}
+
+	schedule.ID, err = h.store.CreatePriceSchedule(schedule)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the new schedule
+	utils.Log.WithFields(logrus.Fields{
+		"productID":  productID,
+		"scheduleID": schedule.ID,
+		"type":       schedule.Type,
+		"price":      schedule.Price,
+		"startsAt":   schedule.StartsAt,
+		"endsAt":     schedule.EndsAt,
+	}).Info("Price change scheduled")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, schedule)
+}
+
+// handleCancelPriceSchedule cancels a price schedule.
+//	@Summary		Cancel a price schedule
+//	@Description	Cancel a pending price schedule, or end a running sale early (requires products:write)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id			path	int	true	"Product ID"
+//	@Param			scheduleId	path	int	true	"Price schedule ID"
+//	@Success		204			"no content"
+//	@Failure		400			{object}	map[string]string	"invalid ID"
+//	@Failure		404			{object}	map[string]string	"price schedule not found"
+//	@Failure		409			{object}	map[string]string	"price schedule already applied"
+//	@Failure		500			{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/price-schedules/{scheduleId} [delete]
+func (h *Handler) handleCancelPriceSchedule(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(c.Param("scheduleId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid price schedule ID"))
+		return
+	}
+
+	if err := h.store.CancelPriceSchedule(productID, scheduleID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the cancellation
+	utils.Log.WithFields(logrus.Fields{
+		"productID":  productID,
+		"scheduleID": scheduleID,
+	}).Info("Price schedule cancelled")
+
+	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
+}
@@ -1,34 +1,159 @@
 package product
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/controller/product/catalogfile"
 	"github.com/youngprinnce/go-ecom/types"
 	"github.com/youngprinnce/go-ecom/utils"
 	"github.com/youngprinnce/go-ecom/middleware"
 )
 
 type Handler struct {
-	store types.ProductStore
+	store           types.ProductStore
+	restockNotifier types.RestockNotifier
 }
 
-func NewHandler(store types.ProductStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.ProductStore, restockNotifier types.RestockNotifier) *Handler {
+	return &Handler{
+		store:           store,
+		restockNotifier: restockNotifier,
+	}
 }
 
 func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 	// Create a subrouter for product routes
 	productRouter := router.Group("/products")
-	productRouter.Use(middleware.JWTAuth(), middleware.AdminOnly()) // Require JWT authentication with admin privileges
+	productRouter.Use(middleware.JWTAuth()) // Require JWT authentication, and a permission per route
+
+	read := middleware.RequirePermission(types.PermissionProductsRead)
+	write := middleware.RequirePermission(types.PermissionProductsWrite)
+	productRouter.GET("", read, h.handleGetProducts)
+	productRouter.GET("/archived", read, h.handleGetArchivedProducts)
+	productRouter.GET("/export", read, h.handleExportProducts)
+	productRouter.POST("", write, h.handleCreateProduct)
+	productRouter.POST("/import", write, h.handleImportProducts)
+	productRouter.GET("/:id", read, h.handleGetProduct)
+	productRouter.PUT("/:id", write, h.handleUpdateProduct)
+	productRouter.PATCH("/:id", write, h.handlePatchProduct)
+	productRouter.PUT("/:id/status", write, h.handleUpdateProductStatus)
+	productRouter.GET("/:id/components", read, h.handleGetBundleComponents)
+	productRouter.PUT("/:id/components", write, h.handleSetBundleComponents)
+	productRouter.DELETE("/:id/components", write, h.handleClearBundleComponents)
+	productRouter.DELETE("/:id", write, h.handleArchiveProduct)
+	productRouter.POST("/:id/restore", write, h.handleRestoreProduct)
+	productRouter.DELETE("/:id/purge", write, h.handlePurgeProduct)
+	productRouter.GET("/:id/price-history", read, h.handleGetPriceHistory)
+	productRouter.GET("/:id/price-schedules", read, h.handleGetPriceSchedules)
+	productRouter.POST("/:id/price-schedules", write, h.handleCreatePriceSchedule)
+	productRouter.DELETE("/:id/price-schedules/:scheduleId", write, h.handleCancelPriceSchedule)
+
+	// Public catalog routes only expose products customers can buy: published ones,
+	// and unlisted ones when asked for directly
+	catalogRouter := router.Group("/catalog/products")
+	catalogRouter.GET("", h.handleGetCatalogProducts)
+	catalogRouter.GET("/:id", h.handleGetCatalogProduct)
+	catalogRouter.GET("/by-slug/:slug", h.handleGetCatalogProductBySlug)
+}
+
+// handleGetCatalogProducts lists the products customers can browse.
+//	@Summary		Browse the catalog
+//	@Description	List all published products
+//	@Tags			catalog
+//	@Produce		json
+//	@Success		200	{array}		types.Product		"list of products"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/catalog/products [get]
+func (h *Handler) handleGetCatalogProducts(c *gin.Context) {
+	products, err := h.store.GetPublishedProducts()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(c.Writer, http.StatusOK, products)
+}
+
+// handleGetCatalogProduct retrieves a single catalog product.
+//	@Summary		Get a catalog product
+//	@Description	Get a product from the catalog by its ID
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			id	path		int					true	"Product ID"
+//	@Success		200	{object}	types.Product		"product"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		404	{object}	map[string]string	"product not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/catalog/products/{id} [get]
+func (h *Handler) handleGetCatalogProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	product, err := h.getProduct(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Archived, draft and scheduled products are hidden from customers
+	if !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, ErrProductNotFound)
+		return
+	}
 
-	productRouter.GET("", h.handleGetProducts)
-	productRouter.POST("", h.handleCreateProduct)
-	productRouter.PUT("/:id", h.handleUpdateProduct)
-	productRouter.DELETE("/:id", h.handleDeleteProduct)
+	utils.WriteJSON(c.Writer, http.StatusOK, product)
+}
+
+// handleGetCatalogProductBySlug retrieves a single catalog product by its slug.
+//	@Summary		Get a catalog product by slug
+//	@Description	Get a product from the catalog by its slug. Slugs a product used before redirect permanently to its current one.
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			slug	path		string				true	"Product slug"
+//	@Success		200		{object}	types.Product		"product"
+//	@Success		301		"moved to the current slug"
+//	@Failure		404		{object}	map[string]string	"product not found"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/catalog/products/by-slug/{slug} [get]
+func (h *Handler) handleGetCatalogProductBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+
+	product, err := h.store.GetProductBySlug(slug)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Archived, draft and scheduled products are hidden from customers
+	if !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, ErrProductNotFound)
+		return
+	}
+
+	// The slug belonged to the product before it was renamed
+	if product.Slug != slug {
+		c.Redirect(http.StatusMovedPermanently, strings.TrimSuffix(c.Request.URL.Path, slug)+product.Slug)
+		return
+	}
+
+	if err := h.loadComponents(product); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, product)
 }
 
 // handleGetProducts retrieves all products.
@@ -49,16 +174,35 @@ func (h *Handler) handleGetProducts(c *gin.Context) {
 	utils.WriteJSON(c.Writer, http.StatusOK, products)
 }
 
+// handleGetArchivedProducts retrieves all archived products.
+//	@Summary		Get archived products
+//	@Description	Get all archived products (requires products:read)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.Product		"list of archived products"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/products/archived [get]
+func (h *Handler) handleGetArchivedProducts(c *gin.Context) {
+	products, err := h.store.GetArchivedProducts()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(c.Writer, http.StatusOK, products)
+}
+
 // handleCreateProduct creates a new product.
 //	@Summary		Create a new product
-//	@Description	Create a new product (admin only)
+//	@Description	Create a new product (requires products:write)
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
 //	@Security		apiKey
 //	@Param			payload	body		types.CreateProductPayload	true	"Product payload"
-//	@Success		201		{object}	types.CreateProductPayload	"created product"
+//	@Success		201		{object}	types.Product				"created product"
 //	@Failure		400		{object}	map[string]string			"invalid request payload"
+//	@Failure		409		{object}	map[string]string			"slug already taken"
 //	@Failure		500		{object}	map[string]string			"internal server error"
 //	@Router			/products [post]
 func (h *Handler) handleCreateProduct(c *gin.Context) {
@@ -75,34 +219,198 @@ func (h *Handler) handleCreateProduct(c *gin.Context) {
 	}
 
 	// Create the product
-	if err := h.store.CreateProduct(p); err != nil {
-		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	productID, err := h.store.CreateProduct(p)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	product, err := h.store.GetProductByID(productID)
+	if err != nil {
+		writeStoreError(c, err)
 		return
 	}
 
 	// Log the creation of a new product
 	utils.Log.WithFields(logrus.Fields{
+		"productID":   productID,
+		"slug":        product.Slug,
 		"name":        p.Name,
 		"description": p.Description,
 		"price":       p.Price,
 		"quantity":    p.Quantity,
 	}).Info("New product created")
 
-	utils.WriteJSON(c.Writer, http.StatusCreated, p)
+	utils.WriteJSON(c.Writer, http.StatusCreated, product)
+}
+
+// handleImportProducts creates or updates products in bulk from a CSV or JSON file.
+//	@Summary		Import products
+//	@Description	Upsert products by SKU from a CSV or JSON file (requires products:write). The file can be sent as the "file" field of a multipart form or as the raw request body. Every row is validated and row-level errors are reported; valid rows are written in a single transaction unless dryRun is set.
+//	@Tags			products
+//	@Accept			text/csv,json,mpfd
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			format	query		string						false	"File format, detected from the file name or Content-Type when omitted"	Enums(csv, json)
+//	@Param			dryRun	query		bool						false	"Validate and report without writing"
+//	@Param			file	formData	file						false	"Import file"
+//	@Success		200		{object}	types.ProductImportReport	"import report"
+//	@Failure		400		{object}	map[string]string			"invalid file"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/products/import [post]
+func (h *Handler) handleImportProducts(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	format := c.Query("format")
+
+	// Read from the uploaded file if there is one, otherwise from the request body
+	body := c.Request.Body
+	if header, err := c.FormFile("file"); err == nil {
+		file, err := header.Open()
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+		body = file
+
+		if format == "" && strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+			format = "csv"
+		}
+	}
+	if format == "" && strings.HasPrefix(c.ContentType(), "text/csv") {
+		format = "csv"
+	}
+	if format == "" {
+		format = "json"
+	}
+
+	var rows []types.ProductImportRow
+	var rowErrors []types.ProductImportRowError
+	var err error
+	switch format {
+	case "csv":
+		rows, rowErrors, err = catalogfile.ParseCSV(body)
+	case "json":
+		rows, err = catalogfile.ParseJSON(body)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := catalogfile.Import(h.store, rows, rowErrors, dryRun)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Log the import
+	utils.Log.WithFields(logrus.Fields{
+		"format":  format,
+		"dryRun":  dryRun,
+		"total":   report.Total,
+		"created": report.Created,
+		"updated": report.Updated,
+		"errors":  len(report.Errors),
+	}).Info("Products imported")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, report)
+}
+
+// handleExportProducts downloads the whole catalog as CSV or JSON.
+//	@Summary		Export products
+//	@Description	Export every product that has not been archived, in the same format the import accepts, leaving out bundles and products without a SKU (requires products:read)
+//	@Tags			products
+//	@Produce		text/csv,json
+//	@Security		apiKey
+//	@Param			format	query		string				false	"File format"	Enums(csv, json)	default(csv)
+//	@Success		200		{file}		file				"export file"
+//	@Failure		400		{object}	map[string]string	"unsupported format"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/products/export [get]
+func (h *Handler) handleExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("unsupported format %q", format))
+		return
+	}
+
+	products, err := h.store.GetProducts()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=products.%s", format))
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv")
+		err = catalogfile.ExportCSV(c.Writer, products)
+	} else {
+		c.Header("Content-Type", "application/json")
+		err = catalogfile.ExportJSON(c.Writer, products)
+	}
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("Failed to export products")
+	}
+}
+
+// handleGetProduct retrieves a single product.
+//	@Summary		Get a product
+//	@Description	Get a product by its ID, including archived products (requires products:read). The ETag header carries the product version to send back in If-Match when updating.
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id				path		int					true	"Product ID"
+//	@Param			If-None-Match	header		string				false	"ETag of a cached copy"
+//	@Success		200				{object}	types.Product		"product"
+//	@Success		304				"not modified"
+//	@Failure		400				{object}	map[string]string	"invalid product ID"
+//	@Failure		404				{object}	map[string]string	"product not found"
+//	@Failure		500				{object}	map[string]string	"internal server error"
+//	@Router			/products/{id} [get]
+func (h *Handler) handleGetProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	product, err := h.getProduct(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(product))
+	if c.GetHeader("If-None-Match") == etag(product) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, product)
 }
 
 // handleUpdateProduct updates an existing product.
 //	@Summary		Update a product
-//	@Description	Update an existing product (admin only)
+//	@Description	Update an existing product (requires products:write). If-Match must carry the ETag of the version being edited; if the product changed since, nothing is written and 412 is returned with the current ETag.
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
 //	@Security		apiKey
-//	@Param			id		path		int							true	"Product ID"
-//	@Param			payload	body		types.CreateProductPayload	true	"Product payload"
-//	@Success		200		{object}	types.Product				"updated product"
-//	@Failure		400		{object}	map[string]string			"invalid product ID or payload"
-//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Param			id			path		int							true	"Product ID"
+//	@Param			If-Match	header		string						true	"ETag of the product version being edited, or * to overwrite any version"
+//	@Param			payload		body		types.CreateProductPayload	true	"Product payload"
+//	@Success		200			{object}	types.Product				"updated product"
+//	@Failure		400			{object}	map[string]string			"invalid product ID or payload, or a digital bundle or bundle component"
+//	@Failure		404			{object}	map[string]string			"product not found"
+//	@Failure		409			{object}	map[string]string			"slug already taken"
+//	@Failure		412			{object}	map[string]string			"product was modified"
+//	@Failure		428			{object}	map[string]string			"missing If-Match header"
+//	@Failure		500			{object}	map[string]string			"internal server error"
 //	@Router			/products/{id} [put]
 func (h *Handler) handleUpdateProduct(c *gin.Context) {
 	productID, err := strconv.Atoi(c.Param("id"))
@@ -111,6 +419,17 @@ func (h *Handler) handleUpdateProduct(c *gin.Context) {
 		return
 	}
 
+	version, ok := h.expectedVersion(c, productID)
+	if !ok {
+		return
+	}
+
+	current, err := h.store.GetProductByID(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
 	var payload types.CreateProductPayload
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		utils.WriteError(c.Writer, http.StatusBadRequest, err)
@@ -125,16 +444,28 @@ func (h *Handler) handleUpdateProduct(c *gin.Context) {
 
 	// Update the product
 	product := types.Product{
-		ID:          productID,
-		Name:        payload.Name,
-		Description: payload.Description,
-		Image:       payload.Image,
-		Price:       payload.Price,
-		Quantity:    payload.Quantity,
+		ID:              productID,
+		SKU:             payload.SKU,
+		Slug:            payload.Slug,
+		Name:            payload.Name,
+		Description:     payload.Description,
+		Image:           payload.Image,
+		Price:           payload.Price,
+		Quantity:        payload.Quantity,
+		IsDigital:       payload.IsDigital,
+		MetaTitle:       payload.MetaTitle,
+		MetaDescription: payload.MetaDescription,
+		Version:         version,
 	}
 
 	if err := h.store.UpdateProduct(product); err != nil {
-		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		h.writeUpdateError(c, productID, err)
+		return
+	}
+
+	updated, err := h.store.GetProductByID(productID)
+	if err != nil {
+		writeStoreError(c, err)
 		return
 	}
 
@@ -145,39 +476,408 @@ func (h *Handler) handleUpdateProduct(c *gin.Context) {
 		"description": product.Description,
 		"price":       product.Price,
 		"quantity":    product.Quantity,
+		"version":     updated.Version,
 	}).Info("Product updated")
 
+	h.notifyIfRestocked(current.Quantity, updated)
+
+	c.Header("ETag", etag(updated))
+	utils.WriteJSON(c.Writer, http.StatusOK, updated)
+}
+
+// handlePatchProduct updates only the fields supplied in a patch.
+//	@Summary		Patch a product
+//	@Description	Partially update a product (requires products:write). Send an RFC 7396 JSON Merge Patch as application/merge-patch+json (or application/json), or an RFC 6902 JSON Patch as application/json-patch+json. The patch is applied to the current sku, slug, name, description, image, price, quantity, isDigital, metaTitle and metaDescription, and the result is validated as a whole. If-Match works as for PUT.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id			path		int							true	"Product ID"
+//	@Param			If-Match	header		string						true	"ETag of the product version being edited, or * to patch any version"
+//	@Param			payload		body		types.ProductPatchDocument	true	"Merge patch with the fields to change, or a JSON Patch array"
+//	@Success		200			{object}	types.Product				"updated product"
+//	@Failure		400			{object}	map[string]string			"invalid product ID, patch or resulting product, or a digital bundle or bundle component"
+//	@Failure		404			{object}	map[string]string			"product not found"
+//	@Failure		409			{object}	map[string]string			"slug already taken"
+//	@Failure		412			{object}	map[string]string			"product was modified"
+//	@Failure		415			{object}	map[string]string			"unsupported patch format"
+//	@Failure		428			{object}	map[string]string			"missing If-Match header"
+//	@Failure		500			{object}	map[string]string			"internal server error"
+//	@Router			/products/{id} [patch]
+func (h *Handler) handlePatchProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	version, ok := h.expectedVersion(c, productID)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.store.GetProductByID(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Don't bother applying a patch that was written against an older version
+	if product.Version != version {
+		h.writeUpdateError(c, productID, types.ErrProductVersionConflict)
+		return
+	}
+
+	current := types.ProductPatchDocument{
+		SKU:             product.SKU,
+		Slug:            product.Slug,
+		Name:            product.Name,
+		Description:     product.Description,
+		Image:           product.Image,
+		Price:           product.Price,
+		Quantity:        product.Quantity,
+		IsDigital:       product.IsDigital,
+		MetaTitle:       product.MetaTitle,
+		MetaDescription: product.MetaDescription,
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case "application/json-patch+json":
+		patched, err = utils.JSONPatch(doc, patch)
+	case "application/merge-patch+json", "application/json":
+		patched, err = utils.MergePatch(doc, patch)
+	default:
+		utils.WriteError(c.Writer, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported patch format %q", c.ContentType()))
+		return
+	}
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Decode strictly so patches touching read-only or unknown fields are rejected
+	var result types.ProductPatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid patch: %v", err))
+		return
+	}
+
+	// Validate the patched product
+	if err := utils.Validate.Struct(result); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product: %v", err))
+		return
+	}
+
+	changes := changedFields(current, result)
+	if len(changes) > 0 {
+		before := product.Quantity
+		if err := h.store.PatchProduct(productID, version, changes); err != nil {
+			h.writeUpdateError(c, productID, err)
+			return
+		}
+
+		if product, err = h.store.GetProductByID(productID); err != nil {
+			writeStoreError(c, err)
+			return
+		}
+
+		// Log the patched fields
+		fields := logrus.Fields{
+			"productID": productID,
+			"version":   product.Version,
+		}
+		for field, value := range changes {
+			fields[field] = value
+		}
+		utils.Log.WithFields(fields).Info("Product patched")
+
+		h.notifyIfRestocked(before, product)
+	}
+
+	c.Header("ETag", etag(product))
 	utils.WriteJSON(c.Writer, http.StatusOK, product)
 }
 
-// handleDeleteProduct deletes a product.
-//	@Summary		Delete a product
-//	@Description	Delete a product (admin only)
+// handleUpdateProductStatus changes the publication status of a product.
+//	@Summary		Update a product's status
+//	@Description	Set a product to draft, published, scheduled or unlisted (requires products:write). Scheduled products need a future publishAt and are published by a background job once it passes.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int									true	"Product ID"
+//	@Param			payload	body		types.UpdateProductStatusPayload	true	"Status payload"
+//	@Success		200		{object}	types.Product						"updated product"
+//	@Failure		400		{object}	map[string]string					"invalid product ID or payload"
+//	@Failure		404		{object}	map[string]string					"product not found"
+//	@Failure		500		{object}	map[string]string					"internal server error"
+//	@Router			/products/{id}/status [put]
+func (h *Handler) handleUpdateProductStatus(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	var payload types.UpdateProductStatusPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+	if payload.Status == types.ProductStatusScheduled && !payload.PublishAt.After(time.Now()) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("publishAt must be in the future"))
+		return
+	}
+
+	if err := h.store.SetProductStatus(productID, payload.Status, payload.PublishAt); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	product, err := h.store.GetProductByID(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the status change
+	utils.Log.WithFields(logrus.Fields{
+		"productID": productID,
+		"status":    product.Status,
+		"publishAt": product.PublishAt,
+	}).Info("Product status updated")
+
+	c.Header("ETag", etag(product))
+	utils.WriteJSON(c.Writer, http.StatusOK, product)
+}
+
+// handleArchiveProduct archives a product.
+//	@Summary		Archive a product
+//	@Description	Soft delete a product so it is hidden from the catalog and checkout (requires products:write)
 //	@Tags			products
 //	@Produce		json
 //	@Security		apiKey
 //	@Param			id	path	int	true	"Product ID"
 //	@Success		204	"no content"
 //	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		404	{object}	map[string]string	"product not found"
 //	@Failure		500	{object}	map[string]string	"internal server error"
 //	@Router			/products/{id} [delete]
-func (h *Handler) handleDeleteProduct(c *gin.Context) {
+func (h *Handler) handleArchiveProduct(c *gin.Context) {
 	productID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
 		return
 	}
 
-	// Delete the product
-	if err := h.store.DeleteProduct(productID); err != nil {
-		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	// Archive the product
+	if err := h.store.ArchiveProduct(productID); err != nil {
+		writeStoreError(c, err)
 		return
 	}
 
-	// Log the deletion of a product
+	// Log the archiving of a product
 	utils.Log.WithFields(logrus.Fields{
 		"productID": productID,
-	}).Info("Product deleted")
+	}).Info("Product archived")
 
 	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
 }
+
+// handleRestoreProduct restores an archived product.
+//	@Summary		Restore a product
+//	@Description	Restore an archived product to the catalog (requires products:write)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Product ID"
+//	@Success		200	{object}	types.Product		"restored product"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		404	{object}	map[string]string	"product not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/restore [post]
+func (h *Handler) handleRestoreProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	// Restore the product
+	if err := h.store.RestoreProduct(productID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	product, err := h.store.GetProductByID(productID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the restoration of a product
+	utils.Log.WithFields(logrus.Fields{
+		"productID": productID,
+	}).Info("Product restored")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, product)
+}
+
+// handlePurgeProduct permanently deletes a product that has never been ordered.
+//	@Summary		Purge a product
+//	@Description	Permanently delete a product that has never been ordered (requires products:write)
+//	@Tags			products
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path	int	true	"Product ID"
+//	@Success		204	"no content"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		404	{object}	map[string]string	"product not found"
+//	@Failure		409	{object}	map[string]string	"product has been ordered"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/products/{id}/purge [delete]
+func (h *Handler) handlePurgeProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	// Purge the product
+	if err := h.store.PurgeProduct(productID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the purge of a product
+	utils.Log.WithFields(logrus.Fields{
+		"productID": productID,
+	}).Info("Product purged")
+
+	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
+}
+
+// changedFields lists the fields that differ between two patch documents, keyed by JSON name
+func changedFields(before, after types.ProductPatchDocument) map[string]interface{} {
+	changes := make(map[string]interface{})
+	if before.SKU != after.SKU {
+		changes["sku"] = after.SKU
+	}
+	if before.Slug != after.Slug {
+		changes["slug"] = after.Slug
+	}
+	if before.Name != after.Name {
+		changes["name"] = after.Name
+	}
+	if before.Description != after.Description {
+		changes["description"] = after.Description
+	}
+	if before.Image != after.Image {
+		changes["image"] = after.Image
+	}
+	if before.Price != after.Price {
+		changes["price"] = after.Price
+	}
+	if before.Quantity != after.Quantity {
+		changes["quantity"] = after.Quantity
+	}
+	if before.IsDigital != after.IsDigital {
+		changes["isDigital"] = after.IsDigital
+	}
+	if before.MetaTitle != after.MetaTitle {
+		changes["metaTitle"] = after.MetaTitle
+	}
+	if before.MetaDescription != after.MetaDescription {
+		changes["metaDescription"] = after.MetaDescription
+	}
+	return changes
+}
+
+// etag formats the version of a product as a strong entity tag
+func etag(p *types.Product) string {
+	return fmt.Sprintf("\"%d\"", p.Version)
+}
+
+// expectedVersion reads the product version an update is based on from the If-Match header.
+// "*" matches whatever version is current. It writes the error response and returns false
+// when the header is missing or malformed.
+func (h *Handler) expectedVersion(c *gin.Context, productID int) (int, bool) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		utils.WriteError(c.Writer, http.StatusPreconditionRequired, fmt.Errorf("If-Match header is required"))
+		return 0, false
+	}
+
+	if ifMatch == "*" {
+		product, err := h.store.GetProductByID(productID)
+		if err != nil {
+			writeStoreError(c, err)
+			return 0, false
+		}
+		return product.Version, true
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), "\""))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid If-Match header"))
+		return 0, false
+	}
+
+	return version, true
+}
+
+// notifyIfRestocked tells the restock notifier about a product whose quantity went from
+// zero to above zero. Notifications are sent in the background so the update isn't held up.
+func (h *Handler) notifyIfRestocked(before int, product *types.Product) {
+	if before <= 0 && product.Quantity > 0 && !product.IsDigital {
+		go h.restockNotifier.NotifyRestocked(*product)
+	}
+}
+
+// writeUpdateError writes the response for a failed update. On a version conflict it
+// answers 412 with the current ETag so the client can reload and retry.
+func (h *Handler) writeUpdateError(c *gin.Context, productID int, err error) {
+	if !errors.Is(err, types.ErrProductVersionConflict) {
+		writeStoreError(c, err)
+		return
+	}
+
+	if current, err := h.store.GetProductByID(productID); err == nil {
+		c.Header("ETag", etag(current))
+	}
+	utils.WriteError(c.Writer, http.StatusPreconditionFailed, err)
+}
+
+// writeStoreError maps product store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrProductNotFound), errors.Is(err, ErrScheduleNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrProductOrdered), errors.Is(err, ErrScheduleOverlap), errors.Is(err, ErrScheduleNotCancellable), errors.Is(err, ErrSlugTaken), errors.Is(err, ErrProductBundled):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	case errors.Is(err, ErrInvalidBundle):
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -3,13 +3,38 @@ package product
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
 )
 
+var (
+	ErrProductNotFound        = errors.New("product not found")
+	ErrProductOrdered         = errors.New("product has been ordered and cannot be purged")
+	ErrScheduleNotFound       = errors.New("price schedule not found")
+	ErrScheduleOverlap        = errors.New("sale overlaps another sale of this product")
+	ErrScheduleNotCancellable = errors.New("price schedule has already been applied")
+	ErrSlugTaken              = errors.New("slug is already used by another product")
+	ErrProductBundled         = errors.New("product is a component of a bundle")
+	ErrInvalidBundle          = errors.New("invalid bundle")
+)
+
+// productColumns lists the product columns in the order scanProduct expects them.
+// The quantity of a bundle is the number of complete bundles its components' stock allows;
+// an archived component makes the bundle unavailable.
+const productColumns = "id, sku, slug, kind, isDigital, name, description, image, price, compareAtPrice, " + bundleQuantity + ", ratingAverage, ratingCount, metaTitle, metaDescription, status, publishAt, version, deletedAt, createdAt"
+
+const bundleQuantity = `IF(kind = 'bundle', (
+	SELECT COALESCE(MIN(IF(c.deletedAt IS NULL, FLOOR(c.quantity / bi.quantity), 0)), 0)
+	FROM product_bundle_items bi JOIN products c ON c.id = bi.componentId
+	WHERE bi.bundleId = products.id
+), quantity)`
+
 type Store struct {
 	db *sql.DB
 }
@@ -18,58 +43,98 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-// GetProductByID retrieves a product by its ID
+// GetProductByID retrieves a product by its ID, including archived products
 func (s *Store) GetProductByID(id int) (*types.Product, error) {
-	var p types.Product
-	query := "SELECT * FROM products WHERE id = ?"
-	if err := s.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.Description, &p.Image, &p.Price, &p.Quantity, &p.CreatedAt); err != nil {
+	query := fmt.Sprintf("SELECT %s FROM products WHERE id = ?", productColumns)
+	p, err := scanProduct(s.db.QueryRow(query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product not found")
+			return nil, ErrProductNotFound
 		}
 		return nil, fmt.Errorf("could not get product: %w", err)
 	}
 
-	return &p, nil
+	return p, nil
+}
+
+// GetProductBySlug retrieves a product by its current slug, or by a slug it used to have.
+// Callers can compare the slug of the result with the requested one to detect the latter.
+func (s *Store) GetProductBySlug(slug string) (*types.Product, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM products WHERE slug = ?
+		UNION ALL
+		SELECT %s FROM products WHERE id = (SELECT productId FROM product_slug_redirects WHERE slug = ?)
+		LIMIT 1
+	`, productColumns, productColumns)
+	p, err := scanProduct(s.db.QueryRow(query, slug, slug))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("could not get product: %w", err)
+	}
+
+	return p, nil
 }
 
-// GetProducts retrieves all products
+// GetProducts retrieves all products that have not been archived
 func (s *Store) GetProducts() ([]*types.Product, error) {
+	return s.queryProducts(fmt.Sprintf("SELECT %s FROM products WHERE deletedAt IS NULL", productColumns))
+}
+
+// GetPublishedProducts retrieves the products listed in the catalog: published and not archived
+func (s *Store) GetPublishedProducts() ([]*types.Product, error) {
+	return s.queryProducts(fmt.Sprintf("SELECT %s FROM products WHERE status = ? AND deletedAt IS NULL", productColumns), types.ProductStatusPublished)
+}
+
+// GetArchivedProducts retrieves all archived products
+func (s *Store) GetArchivedProducts() ([]*types.Product, error) {
+	return s.queryProducts(fmt.Sprintf("SELECT %s FROM products WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC", productColumns))
+}
+
+// CreateProduct creates a new draft product and returns its ID. Without an explicit slug,
+// one is generated from the name and suffixed with a number if it is already taken.
+func (s *Store) CreateProduct(p types.CreateProductPayload) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, "SELECT * FROM products")
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("could not get products: %w", err)
+		return 0, fmt.Errorf("could not create product: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	products := make([]*types.Product, 0)
-	for rows.Next() {
-		var p types.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Image, &p.Price, &p.Quantity, &p.CreatedAt); err != nil {
-			return nil, fmt.Errorf("could not get product: %w", err)
+	slug := p.Slug
+	if slug == "" {
+		if slug, err = uniqueSlug(ctx, tx, utils.Slugify(p.Name), 0); err != nil {
+			return 0, err
 		}
-		products = append(products, &p)
+	} else if taken, err := slugTaken(ctx, tx, slug, 0); err != nil {
+		return 0, err
+	} else if taken {
+		return 0, ErrSlugTaken
 	}
 
-	return products, nil
-}
-
-// CreateProduct creates a new product
-func (s *Store) CreateProduct(p types.CreateProductPayload) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	query := "INSERT INTO products (sku, slug, name, description, image, price, quantity, isDigital, metaTitle, metaDescription) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := tx.ExecContext(ctx, query, nullString(p.SKU), slug, p.Name, p.Description, p.Image, p.Price, p.Quantity, p.IsDigital, p.MetaTitle, p.MetaDescription)
+	if err != nil {
+		return 0, fmt.Errorf("could not create product: %w", err)
+	}
 
-	query := "INSERT INTO products (name, description, image, price, quantity) VALUES (?, ?, ?, ?, ?)"
-	_, err := s.db.ExecContext(ctx, query, p.Name, p.Description, p.Image, p.Price, p.Quantity)
+	productID, err := res.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("could not create product: %w", err)
+		return 0, fmt.Errorf("could not create product: %w", err)
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not create product: %w", err)
+	}
+
+	return int(productID), nil
 }
 
-// GetProductsByIDs retrieves products by their IDs
+// GetProductsByIDs retrieves products by their IDs. Only products customers can buy are
+// returned, so archived, draft and scheduled products can't be checked out.
 func (s *Store) GetProductsByIDs(productIds []int) ([]types.Product, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -82,7 +147,9 @@ func (s *Store) GetProductsByIDs(productIds []int) ([]types.Product, error) {
 		args[i] = id
 	}
 
-	query := fmt.Sprintf("SELECT * FROM products WHERE id IN (%s)", strings.Join(placeholders, ","))
+	args = append(args, types.ProductStatusPublished, types.ProductStatusUnlisted)
+
+	query := fmt.Sprintf("SELECT %s FROM products WHERE id IN (%s) AND status IN (?, ?) AND deletedAt IS NULL", productColumns, strings.Join(placeholders, ","))
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("could not get products: %w", err)
@@ -91,40 +158,1049 @@ func (s *Store) GetProductsByIDs(productIds []int) ([]types.Product, error) {
 
 	products := make([]types.Product, 0)
 	for rows.Next() {
-		var p types.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Image, &p.Price, &p.Quantity, &p.CreatedAt); err != nil {
+		p, err := scanProduct(rows)
+		if err != nil {
 			return nil, fmt.Errorf("could not get product: %w", err)
 		}
-		products = append(products, p)
+		products = append(products, *p)
 	}
 
 	return products, nil
 }
 
-// UpdateProduct updates an existing product
+// GetProductsBySKUs retrieves products, including archived ones, by their SKUs
+func (s *Store) GetProductsBySKUs(skus []string) ([]types.Product, error) {
+	if len(skus) == 0 {
+		return []types.Product{}, nil
+	}
+
+	placeholders := make([]string, len(skus))
+	args := make([]interface{}, len(skus))
+	for i, sku := range skus {
+		placeholders[i] = "?"
+		args[i] = sku
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM products WHERE sku IN (%s)", productColumns, strings.Join(placeholders, ","))
+	products, err := s.queryProducts(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Product, len(products))
+	for i, p := range products {
+		result[i] = *p
+	}
+
+	return result, nil
+}
+
+// UpsertProducts inserts or updates products by SKU in a single transaction, and moves those
+// with a status to it. Either every row is written or none is.
+func (s *Store) UpsertProducts(rows []types.ProductImportRow) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not import products: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO products (sku, name, description, image, price, quantity)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			name = VALUES(name),
+			description = VALUES(description),
+			image = VALUES(image),
+			quantity = VALUES(quantity),
+			version = version + 1
+	`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not import products: %w", err)
+	}
+	defer stmt.Close()
+
+	created, updated := 0, 0
+	for _, row := range rows {
+		// Lock the existing product, if any, so its price can be changed like UpdateProduct does
+		var productID int
+		var oldPrice float64
+		var compareAtPrice sql.NullFloat64
+		err := tx.QueryRowContext(ctx, "SELECT id, price, compareAtPrice FROM products WHERE sku = ? FOR UPDATE", row.SKU).Scan(&productID, &oldPrice, &compareAtPrice)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, 0, fmt.Errorf("could not import product %s: %w", row.SKU, err)
+		}
+
+		res, err := stmt.ExecContext(ctx, row.SKU, row.Name, row.Description, row.Image, row.Price, row.Quantity)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not import product %s: %w", row.SKU, err)
+		}
+
+		if productID != 0 {
+			if err := changeRegularPrice(ctx, tx, productID, row.Price, oldPrice, compareAtPrice, "import"); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		// MySQL reports 1 affected row for an insert and 2 for an update
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not import product %s: %w", row.SKU, err)
+		}
+		switch n {
+		case 1:
+			created++
+
+			// Give new products a slug, just like CreateProduct does
+			newID, err := res.LastInsertId()
+			if err != nil {
+				return 0, 0, fmt.Errorf("could not import product %s: %w", row.SKU, err)
+			}
+			productID = int(newID)
+			slug, err := uniqueSlug(ctx, tx, utils.Slugify(row.Name), productID)
+			if err != nil {
+				return 0, 0, err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE products SET slug = ? WHERE id = ?", slug, productID); err != nil {
+				return 0, 0, fmt.Errorf("could not import product %s: %w", row.SKU, err)
+			}
+		default:
+			updated++
+		}
+
+		if row.Status != "" {
+			set, args := statusAssignments(row.Status, row.PublishAt)
+			if _, err := tx.ExecContext(ctx, "UPDATE products SET "+set+" WHERE id = ?", append(args, productID)...); err != nil {
+				return 0, 0, fmt.Errorf("could not import product %s: %w", row.SKU, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("could not import products: %w", err)
+	}
+
+	return created, updated, nil
+}
+
+// UpdateProduct updates an existing product if it is still at p.Version, and bumps its version.
+// It returns types.ErrProductVersionConflict when the product was changed in the meantime.
+// Price changes are recorded in the price history.
 func (s *Store) UpdateProduct(p types.Product) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := "UPDATE products SET name = ?, description = ?, image = ?, price = ?, quantity = ? WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, p.Name, p.Description, p.Image, p.Price, p.Quantity, p.ID)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("could not update product: %w", err)
 	}
+	defer tx.Rollback()
+
+	var oldPrice float64
+	var compareAtPrice sql.NullFloat64
+	var oldSlug sql.NullString
+	var version int
+	if err := tx.QueryRowContext(ctx, "SELECT price, compareAtPrice, slug, version FROM products WHERE id = ? FOR UPDATE", p.ID).Scan(&oldPrice, &compareAtPrice, &oldSlug, &version); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("could not update product: %w", err)
+	}
+	if version != p.Version {
+		return types.ErrProductVersionConflict
+	}
+	if p.IsDigital {
+		if err := checkCanBeDigital(ctx, tx, p.ID); err != nil {
+			return err
+		}
+	}
+
+	query := "UPDATE products SET sku = ?, name = ?, description = ?, image = ?, quantity = ?, isDigital = ?, metaTitle = ?, metaDescription = ?, version = version + 1 WHERE id = ?"
+	if _, err := tx.ExecContext(ctx, query, nullString(p.SKU), p.Name, p.Description, p.Image, p.Quantity, p.IsDigital, p.MetaTitle, p.MetaDescription, p.ID); err != nil {
+		return fmt.Errorf("could not update product: %w", err)
+	}
+
+	// An empty slug keeps the current one
+	if p.Slug != "" {
+		if err := changeSlug(ctx, tx, p.ID, oldSlug.String, p.Slug); err != nil {
+			return err
+		}
+	}
+
+	if err := changeRegularPrice(ctx, tx, p.ID, p.Price, oldPrice, compareAtPrice, "manual"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not update product: %w", err)
+	}
 
 	return nil
 }
 
-// DeleteProduct deletes a product by its ID
-func (s *Store) DeleteProduct(productID int) error {
+// patchableColumns maps the fields of types.ProductPatchDocument to their columns,
+// except slug, which is handled by changeSlug, and price, handled by changeRegularPrice.
+var patchableColumns = map[string]string{
+	"sku":             "sku",
+	"name":            "name",
+	"description":     "description",
+	"image":           "image",
+	"quantity":        "quantity",
+	"isDigital":       "isDigital",
+	"metaTitle":       "metaTitle",
+	"metaDescription": "metaDescription",
+}
+
+// PatchProduct updates only the changed columns of a product, keyed by their JSON field
+// names, if the product is still at the given version. Like UpdateProduct it bumps the
+// version and records price changes.
+func (s *Store) PatchProduct(productID, version int, changes map[string]interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := "DELETE FROM products WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, productID)
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		if field == "slug" || field == "price" {
+			continue
+		}
+		if _, ok := patchableColumns[field]; !ok {
+			return fmt.Errorf("field %q can't be patched", field)
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	assignments := make([]string, 0, len(fields)+1)
+	args := make([]interface{}, 0, len(fields)+1)
+	for _, field := range fields {
+		value := changes[field]
+		if field == "sku" {
+			value = nullString(value.(string))
+		}
+		assignments = append(assignments, patchableColumns[field]+" = ?")
+		args = append(args, value)
+	}
+	assignments = append(assignments, "version = version + 1")
+	args = append(args, productID)
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("could not delete product: %w", err)
+		return fmt.Errorf("could not patch product: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current int
+	var oldPrice float64
+	var compareAtPrice sql.NullFloat64
+	var oldSlug sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT version, price, compareAtPrice, slug FROM products WHERE id = ? FOR UPDATE", productID).Scan(&current, &oldPrice, &compareAtPrice, &oldSlug); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("could not patch product: %w", err)
+	}
+	if current != version {
+		return types.ErrProductVersionConflict
+	}
+	if isDigital, _ := changes["isDigital"].(bool); isDigital {
+		if err := checkCanBeDigital(ctx, tx, productID); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE products SET %s WHERE id = ?", strings.Join(assignments, ", "))
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not patch product: %w", err)
+	}
+
+	if slug, ok := changes["slug"].(string); ok {
+		if err := changeSlug(ctx, tx, productID, oldSlug.String, slug); err != nil {
+			return err
+		}
+	}
+
+	if price, ok := changes["price"].(float64); ok {
+		if err := changeRegularPrice(ctx, tx, productID, price, oldPrice, compareAtPrice, "manual"); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not patch product: %w", err)
 	}
 
 	return nil
 }
+
+// SetProductStatus changes the publication status of a product. publishAt is kept for
+// scheduled products; publishing stamps the current time unless the product already was
+// published, and the other statuses clear it.
+func (s *Store) SetProductStatus(productID int, status string, publishAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set, args := statusAssignments(status, publishAt)
+	res, err := s.db.ExecContext(ctx, "UPDATE products SET "+set+", version = version + 1 WHERE id = ?", append(args, productID)...)
+	if err != nil {
+		return fmt.Errorf("could not update product status: %w", err)
+	}
+
+	return s.checkAffected(ctx, res, productID)
+}
+
+// statusAssignments returns the SET clause, and its arguments, that move a product to status.
+// Publishing keeps the publishAt of products that are already published.
+func statusAssignments(status string, publishAt *time.Time) (string, []interface{}) {
+	switch status {
+	case types.ProductStatusScheduled:
+		return "status = ?, publishAt = ?", []interface{}{status, publishAt}
+	case types.ProductStatusPublished:
+		return "publishAt = IF(status = ?, publishAt, CURRENT_TIMESTAMP), status = ?", []interface{}{status, status}
+	default:
+		return "status = ?, publishAt = NULL", []interface{}{status}
+	}
+}
+
+// PublishDueProducts publishes the scheduled products whose publishAt has passed.
+// It is meant to be run periodically and returns how many products were published.
+func (s *Store) PublishDueProducts() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := "UPDATE products SET status = ?, version = version + 1 WHERE status = ? AND publishAt <= CURRENT_TIMESTAMP"
+	res, err := s.db.ExecContext(ctx, query, types.ProductStatusPublished, types.ProductStatusScheduled)
+	if err != nil {
+		return 0, fmt.Errorf("could not publish scheduled products: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("could not publish scheduled products: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// GetBundleComponents retrieves the products a bundle consists of
+func (s *Store) GetBundleComponents(bundleID int) ([]types.BundleComponent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT bi.componentId, c.name, bi.quantity
+		FROM product_bundle_items bi JOIN products c ON c.id = bi.componentId
+		WHERE bi.bundleId = ?
+		ORDER BY bi.componentId
+	`, bundleID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get bundle components: %w", err)
+	}
+	defer rows.Close()
+
+	components := make([]types.BundleComponent, 0)
+	for rows.Next() {
+		var component types.BundleComponent
+		if err := rows.Scan(&component.ProductID, &component.Name, &component.Quantity); err != nil {
+			return nil, fmt.Errorf("could not scan bundle component: %w", err)
+		}
+		components = append(components, component)
+	}
+
+	return components, rows.Err()
+}
+
+// GetBundlesContaining retrieves the bundles that have the product as a component, with the
+// quantity their components' stock allows. Archived bundles are left out.
+func (s *Store) GetBundlesContaining(componentID int) ([]types.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT %s FROM products
+		WHERE id IN (SELECT bundleId FROM product_bundle_items WHERE componentId = ?) AND deletedAt IS NULL
+	`, productColumns)
+	rows, err := s.db.QueryContext(ctx, query, componentID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get bundles: %w", err)
+	}
+	defer rows.Close()
+
+	bundles := make([]types.Product, 0)
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get bundle: %w", err)
+		}
+		bundles = append(bundles, *p)
+	}
+
+	return bundles, rows.Err()
+}
+
+// SetBundleComponents turns a product into a bundle of the given components, replacing
+// any components it had. Components must be simple products that have not been archived,
+// and a product that is part of a bundle can't become a bundle itself. Neither the bundle
+// nor its components can be digital, as bundles are in stock when their components are.
+func (s *Store) SetBundleComponents(bundleID int, components []types.BundleComponent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not set bundle components: %w", err)
+	}
+	defer tx.Rollback()
+
+	var bundleIsDigital bool
+	if err := tx.QueryRowContext(ctx, "SELECT isDigital FROM products WHERE id = ? FOR UPDATE", bundleID).Scan(&bundleIsDigital); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("could not set bundle components: %w", err)
+	}
+	if bundleIsDigital {
+		return fmt.Errorf("%w: product %d is digital", ErrInvalidBundle, bundleID)
+	}
+
+	var bundled int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM product_bundle_items WHERE componentId = ?", bundleID).Scan(&bundled); err != nil {
+		return fmt.Errorf("could not set bundle components: %w", err)
+	}
+	if bundled > 0 {
+		return fmt.Errorf("%w: product %d is a component of another bundle", ErrInvalidBundle, bundleID)
+	}
+
+	seen := make(map[int]bool, len(components))
+	for _, component := range components {
+		if component.ProductID == bundleID {
+			return fmt.Errorf("%w: a bundle can't contain itself", ErrInvalidBundle)
+		}
+		if seen[component.ProductID] {
+			return fmt.Errorf("%w: product %d is listed more than once", ErrInvalidBundle, component.ProductID)
+		}
+		seen[component.ProductID] = true
+
+		var kind string
+		var isDigital bool
+		var deletedAt sql.NullTime
+		err := tx.QueryRowContext(ctx, "SELECT kind, isDigital, deletedAt FROM products WHERE id = ?", component.ProductID).Scan(&kind, &isDigital, &deletedAt)
+		if err == sql.ErrNoRows || deletedAt.Valid {
+			return fmt.Errorf("%w: product %d not found", ErrInvalidBundle, component.ProductID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not set bundle components: %w", err)
+		}
+		if kind != types.ProductKindSimple {
+			return fmt.Errorf("%w: product %d is a bundle", ErrInvalidBundle, component.ProductID)
+		}
+		if isDigital {
+			return fmt.Errorf("%w: product %d is digital", ErrInvalidBundle, component.ProductID)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_bundle_items WHERE bundleId = ?", bundleID); err != nil {
+		return fmt.Errorf("could not set bundle components: %w", err)
+	}
+
+	for _, component := range components {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO product_bundle_items (bundleId, componentId, quantity) VALUES (?, ?, ?)", bundleID, component.ProductID, component.Quantity); err != nil {
+			return fmt.Errorf("could not set bundle components: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET kind = ?, version = version + 1 WHERE id = ?", types.ProductKindBundle, bundleID); err != nil {
+		return fmt.Errorf("could not set bundle components: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// checkCanBeDigital checks that a product can be made digital. Digital products have no stock,
+// so bundles, whose stock comes from their components, and their components can't be digital.
+func checkCanBeDigital(ctx context.Context, tx *sql.Tx, productID int) error {
+	var kind string
+	var bundled int
+	err := tx.QueryRowContext(ctx, "SELECT kind, (SELECT COUNT(*) FROM product_bundle_items WHERE componentId = products.id) FROM products WHERE id = ?", productID).Scan(&kind, &bundled)
+	if err != nil {
+		return fmt.Errorf("could not check product %d: %w", productID, err)
+	}
+	if kind == types.ProductKindBundle {
+		return fmt.Errorf("%w: product %d is a bundle, which can't be digital", ErrInvalidBundle, productID)
+	}
+	if bundled > 0 {
+		return fmt.Errorf("%w: product %d is a component of a bundle, which can't be digital", ErrInvalidBundle, productID)
+	}
+	return nil
+}
+
+// ClearBundleComponents removes the components of a bundle, turning it back into a simple product
+func (s *Store) ClearBundleComponents(bundleID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not clear bundle components: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE products SET kind = ?, version = version + 1 WHERE id = ?", types.ProductKindSimple, bundleID)
+	if err != nil {
+		return fmt.Errorf("could not clear bundle components: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("could not clear bundle components: %w", err)
+	} else if n == 0 {
+		return ErrProductNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_bundle_items WHERE bundleId = ?", bundleID); err != nil {
+		return fmt.Errorf("could not clear bundle components: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ArchiveProduct soft deletes a product by stamping its deletedAt column.
+// Archived products keep their order history but disappear from the catalog and checkout.
+func (s *Store) ArchiveProduct(productID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := "UPDATE products SET deletedAt = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND deletedAt IS NULL"
+	res, err := s.db.ExecContext(ctx, query, productID)
+	if err != nil {
+		return fmt.Errorf("could not archive product: %w", err)
+	}
+
+	return s.checkAffected(ctx, res, productID)
+}
+
+// RestoreProduct brings an archived product back into the catalog
+func (s *Store) RestoreProduct(productID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := "UPDATE products SET deletedAt = NULL, version = version + 1 WHERE id = ? AND deletedAt IS NOT NULL"
+	res, err := s.db.ExecContext(ctx, query, productID)
+	if err != nil {
+		return fmt.Errorf("could not restore product: %w", err)
+	}
+
+	return s.checkAffected(ctx, res, productID)
+}
+
+// PurgeProduct permanently deletes a product. Only products that have never been
+// ordered can be purged; anything else must stay archived to preserve order history.
+func (s *Store) PurgeProduct(productID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not purge product: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM products WHERE id = ? FOR UPDATE", productID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("could not purge product: %w", err)
+	}
+
+	var ordered int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM order_items WHERE productId = ?", productID).Scan(&ordered); err != nil {
+		return fmt.Errorf("could not purge product: %w", err)
+	}
+	if ordered > 0 {
+		return ErrProductOrdered
+	}
+
+	var bundled int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM product_bundle_items WHERE componentId = ?", productID).Scan(&bundled); err != nil {
+		return fmt.Errorf("could not purge product: %w", err)
+	}
+	if bundled > 0 {
+		return ErrProductBundled
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM products WHERE id = ?", productID); err != nil {
+		return fmt.Errorf("could not purge product: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// queryProducts runs a product query and scans every row
+func (s *Store) queryProducts(query string, args ...interface{}) ([]*types.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get products: %w", err)
+	}
+	defer rows.Close()
+
+	products := make([]*types.Product, 0)
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get product: %w", err)
+		}
+		products = append(products, p)
+	}
+
+	return products, nil
+}
+
+// checkAffected reports ErrProductNotFound when an archive or restore touched no rows
+// because the product does not exist. A product already in the requested state is not an error.
+func (s *Store) checkAffected(ctx context.Context, res sql.Result, productID int) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var id int
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM products WHERE id = ?", productID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetPriceHistory retrieves the price changes of a product, newest first
+func (s *Store) GetPriceHistory(productID int) ([]types.PriceHistoryEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, productId, price, compareAtPrice, source, scheduleId, createdAt
+		FROM product_price_history
+		WHERE productId = ?
+		ORDER BY createdAt DESC, id DESC
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get price history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make([]types.PriceHistoryEntry, 0)
+	for rows.Next() {
+		var e types.PriceHistoryEntry
+		var compareAtPrice sql.NullFloat64
+		var scheduleID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.ProductID, &e.Price, &compareAtPrice, &e.Source, &scheduleID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not get price history: %w", err)
+		}
+		if compareAtPrice.Valid {
+			e.CompareAtPrice = &compareAtPrice.Float64
+		}
+		if scheduleID.Valid {
+			id := int(scheduleID.Int64)
+			e.ScheduleID = &id
+		}
+		history = append(history, e)
+	}
+
+	return history, nil
+}
+
+// GetPriceSchedules retrieves all price schedules of a product, soonest first
+func (s *Store) GetPriceSchedules(productID int) ([]types.PriceSchedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, productId, type, price, startsAt, endsAt, status, createdAt
+		FROM product_price_schedules
+		WHERE productId = ?
+		ORDER BY startsAt ASC, id ASC
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get price schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]types.PriceSchedule, 0)
+	for rows.Next() {
+		var ps types.PriceSchedule
+		var endsAt sql.NullTime
+		if err := rows.Scan(&ps.ID, &ps.ProductID, &ps.Type, &ps.Price, &ps.StartsAt, &endsAt, &ps.Status, &ps.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not get price schedules: %w", err)
+		}
+		if endsAt.Valid {
+			ps.EndsAt = &endsAt.Time
+		}
+		schedules = append(schedules, ps)
+	}
+
+	return schedules, nil
+}
+
+// CreatePriceSchedule schedules a future price change. Sales of the same product may not overlap.
+func (s *Store) CreatePriceSchedule(ps types.PriceSchedule) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not create price schedule: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the product so concurrent schedules are checked one at a time
+	var id int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM products WHERE id = ? FOR UPDATE", ps.ProductID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrProductNotFound
+		}
+		return 0, fmt.Errorf("could not create price schedule: %w", err)
+	}
+
+	var endsAt sql.NullTime
+	if ps.EndsAt != nil {
+		endsAt = sql.NullTime{Time: *ps.EndsAt, Valid: true}
+	}
+
+	if ps.Type == "sale" {
+		var overlapping int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM product_price_schedules
+			WHERE productId = ? AND type = 'sale' AND status IN ('scheduled', 'active')
+				AND startsAt < ? AND endsAt > ?
+		`, ps.ProductID, endsAt, ps.StartsAt).Scan(&overlapping); err != nil {
+			return 0, fmt.Errorf("could not create price schedule: %w", err)
+		}
+		if overlapping > 0 {
+			return 0, ErrScheduleOverlap
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO product_price_schedules (productId, type, price, startsAt, endsAt)
+		VALUES (?, ?, ?, ?, ?)
+	`, ps.ProductID, ps.Type, ps.Price, ps.StartsAt, endsAt)
+	if err != nil {
+		return 0, fmt.Errorf("could not create price schedule: %w", err)
+	}
+
+	scheduleID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("could not create price schedule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not create price schedule: %w", err)
+	}
+
+	return int(scheduleID), nil
+}
+
+// CancelPriceSchedule cancels a pending price schedule. Cancelling a running sale ends it
+// right away and restores the regular price.
+func (s *Store) CancelPriceSchedule(productID, scheduleID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not cancel price schedule: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRowContext(ctx, "SELECT status FROM product_price_schedules WHERE id = ? AND productId = ? FOR UPDATE", scheduleID, productID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrScheduleNotFound
+		}
+		return fmt.Errorf("could not cancel price schedule: %w", err)
+	}
+
+	switch status {
+	case "scheduled":
+	case "active":
+		if err := endSale(ctx, tx, productID, scheduleID, "sale_cancelled"); err != nil {
+			return err
+		}
+	default:
+		return ErrScheduleNotCancellable
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE product_price_schedules SET status = 'cancelled' WHERE id = ?", scheduleID); err != nil {
+		return fmt.Errorf("could not cancel price schedule: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ApplyDuePriceSchedules starts the price schedules whose start time has passed and ends
+// the sales whose end time has passed. It returns how many schedules were applied.
+func (s *Store) ApplyDuePriceSchedules() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id
+		FROM product_price_schedules
+		WHERE (status = 'scheduled' AND startsAt <= CURRENT_TIMESTAMP)
+			OR (status = 'active' AND endsAt <= CURRENT_TIMESTAMP)
+		ORDER BY startsAt ASC, id ASC
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("could not get due price schedules: %w", err)
+	}
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("could not get due price schedules: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	applied := 0
+	for _, id := range ids {
+		if err := s.applyPriceSchedule(ctx, id); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// applyPriceSchedule moves a single due schedule to its next state in its own transaction
+func (s *Store) applyPriceSchedule(ctx context.Context, scheduleID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not apply price schedule %d: %w", scheduleID, err)
+	}
+	defer tx.Rollback()
+
+	var ps types.PriceSchedule
+	var ended bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT productId, type, price, status, endsAt IS NOT NULL AND endsAt <= CURRENT_TIMESTAMP
+		FROM product_price_schedules
+		WHERE id = ?
+		FOR UPDATE
+	`, scheduleID).Scan(&ps.ProductID, &ps.Type, &ps.Price, &ps.Status, &ended); err != nil {
+		return fmt.Errorf("could not apply price schedule %d: %w", scheduleID, err)
+	}
+
+	var price float64
+	var compareAtPrice sql.NullFloat64
+	if err := tx.QueryRowContext(ctx, "SELECT price, compareAtPrice FROM products WHERE id = ? FOR UPDATE", ps.ProductID).Scan(&price, &compareAtPrice); err != nil {
+		return fmt.Errorf("could not apply price schedule %d: %w", scheduleID, err)
+	}
+
+	status := "completed"
+	switch {
+	case ps.Status == "active":
+		if err := endSale(ctx, tx, ps.ProductID, scheduleID, "sale_end"); err != nil {
+			return err
+		}
+	case ps.Type == "price":
+		// While a sale is running the new regular price becomes the compare-at price,
+		// and takes over as the selling price when the sale ends
+		if compareAtPrice.Valid {
+			compareAtPrice.Float64 = ps.Price
+		} else {
+			price = ps.Price
+		}
+		if err := setPrice(ctx, tx, ps.ProductID, price, compareAtPrice, "schedule", &scheduleID); err != nil {
+			return err
+		}
+	case ended:
+		// The whole sale window passed before the scheduler got to it, so there is nothing to apply
+	default:
+		if !compareAtPrice.Valid {
+			compareAtPrice = sql.NullFloat64{Float64: price, Valid: true}
+		}
+		if err := setPrice(ctx, tx, ps.ProductID, ps.Price, compareAtPrice, "sale_start", &scheduleID); err != nil {
+			return err
+		}
+		status = "active"
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE product_price_schedules SET status = ? WHERE id = ?", status, scheduleID); err != nil {
+		return fmt.Errorf("could not apply price schedule %d: %w", scheduleID, err)
+	}
+
+	return tx.Commit()
+}
+
+// endSale restores the regular price of a product on sale
+func endSale(ctx context.Context, tx *sql.Tx, productID, scheduleID int, source string) error {
+	var price float64
+	var compareAtPrice sql.NullFloat64
+	if err := tx.QueryRowContext(ctx, "SELECT price, compareAtPrice FROM products WHERE id = ? FOR UPDATE", productID).Scan(&price, &compareAtPrice); err != nil {
+		return fmt.Errorf("could not end sale: %w", err)
+	}
+
+	if compareAtPrice.Valid {
+		price = compareAtPrice.Float64
+	}
+
+	return setPrice(ctx, tx, productID, price, sql.NullFloat64{}, source, &scheduleID)
+}
+
+// setPrice updates the price and compare-at price of a product and records the change
+func setPrice(ctx context.Context, tx *sql.Tx, productID int, price float64, compareAtPrice sql.NullFloat64, source string, scheduleID *int) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET price = ?, compareAtPrice = ?, version = version + 1 WHERE id = ?", price, compareAtPrice, productID); err != nil {
+		return fmt.Errorf("could not update price: %w", err)
+	}
+
+	return recordPriceChange(ctx, tx, productID, price, compareAtPrice, source, scheduleID)
+}
+
+// changeRegularPrice sets the price of a product from an update or import and records the
+// change. Sending the current selling price back changes nothing. While a sale is running the
+// new regular price becomes the compare-at price, and takes over as the selling price when the
+// sale ends, just like a scheduled price change. Sending the regular price back during a sale
+// changes nothing either.
+func changeRegularPrice(ctx context.Context, tx *sql.Tx, productID int, price, oldPrice float64, compareAtPrice sql.NullFloat64, source string) error {
+	if price == oldPrice {
+		return nil
+	}
+	if compareAtPrice.Valid {
+		if price == compareAtPrice.Float64 {
+			return nil
+		}
+		compareAtPrice.Float64 = price
+		price = oldPrice
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET price = ?, compareAtPrice = ? WHERE id = ?", price, compareAtPrice, productID); err != nil {
+		return fmt.Errorf("could not update price: %w", err)
+	}
+
+	return recordPriceChange(ctx, tx, productID, price, compareAtPrice, source, nil)
+}
+
+// recordPriceChange appends an entry to the price history of a product
+func recordPriceChange(ctx context.Context, tx *sql.Tx, productID int, price float64, compareAtPrice sql.NullFloat64, source string, scheduleID *int) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO product_price_history (productId, price, compareAtPrice, source, scheduleId)
+		VALUES (?, ?, ?, ?, ?)
+	`, productID, price, compareAtPrice, source, scheduleID)
+	if err != nil {
+		return fmt.Errorf("could not record price change: %w", err)
+	}
+
+	return nil
+}
+
+// slugTaken reports whether a slug is used by another product, either as its current
+// slug or as one that still redirects to it
+func slugTaken(ctx context.Context, tx *sql.Tx, slug string, productID int) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM products WHERE slug = ? AND id <> ?) +
+			(SELECT COUNT(*) FROM product_slug_redirects WHERE slug = ? AND productId <> ?)
+	`, slug, productID, slug, productID).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("could not check slug: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// uniqueSlug returns base, or base with the first numeric suffix that is not taken
+func uniqueSlug(ctx context.Context, tx *sql.Tx, base string, productID int) (string, error) {
+	slug := base
+	for i := 2; ; i++ {
+		taken, err := slugTaken(ctx, tx, slug, productID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// changeSlug gives a product a new slug and keeps the old one as a redirect,
+// so links to the old URL keep working
+func changeSlug(ctx context.Context, tx *sql.Tx, productID int, oldSlug, newSlug string) error {
+	if newSlug == oldSlug {
+		return nil
+	}
+
+	taken, err := slugTaken(ctx, tx, newSlug, productID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSlugTaken
+	}
+
+	// Going back to a previous slug turns its redirect back into the real thing
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_slug_redirects WHERE slug = ?", newSlug); err != nil {
+		return fmt.Errorf("could not change slug: %w", err)
+	}
+
+	if oldSlug != "" {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO product_slug_redirects (slug, productId) VALUES (?, ?)", oldSlug, productID); err != nil {
+			return fmt.Errorf("could not change slug: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET slug = ? WHERE id = ?", newSlug, productID); err != nil {
+		return fmt.Errorf("could not change slug: %w", err)
+	}
+
+	return nil
+}
+
+// nullString stores empty strings as NULL so optional unique columns don't collide
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct scans a row selected with productColumns into a product
+func scanProduct(row scanner) (*types.Product, error) {
+	var p types.Product
+	var sku, slug sql.NullString
+	var compareAtPrice sql.NullFloat64
+	var publishAt, deletedAt sql.NullTime
+	if err := row.Scan(&p.ID, &sku, &slug, &p.Kind, &p.IsDigital, &p.Name, &p.Description, &p.Image, &p.Price, &compareAtPrice, &p.Quantity, &p.AverageRating, &p.ReviewCount, &p.MetaTitle, &p.MetaDescription, &p.Status, &publishAt, &p.Version, &deletedAt, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	p.SKU = sku.String
+	p.Slug = slug.String
+	if compareAtPrice.Valid {
+		p.CompareAtPrice = &compareAtPrice.Float64
+	}
+	if publishAt.Valid {
+		p.PublishAt = &publishAt.Time
+	}
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,151 @@
+package recommendation
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 50
+)
+
+type Handler struct {
+	store        types.RecommendationStore
+	productStore types.ProductStore
+}
+
+func NewHandler(store types.RecommendationStore, productStore types.ProductStore) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	// Public routes, recommendations only ever include published products
+	router.GET("/catalog/products/:id/related", h.handleGetRelatedProducts)
+	router.POST("/catalog/recommendations", h.handleGetCartRecommendations)
+}
+
+// handleGetRelatedProducts lists the products frequently bought together with a product.
+//	@Summary		Get related products
+//	@Description	List the products most often bought together with a product, best match first. Recommendations are computed periodically from paid orders.
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			id		path		int					true	"Product ID"
+//	@Param			limit	query		int					false	"Maximum number of products (default 10, at most 50)"
+//	@Success		200		{array}		types.Product		"related products"
+//	@Failure		400		{object}	map[string]string	"invalid product ID or limit"
+//	@Failure		404		{object}	map[string]string	"product not found"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/catalog/products/{id}/related [get]
+func (h *Handler) handleGetRelatedProducts(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil || !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+
+	h.writeRecommendations(c, []int{productID}, limit)
+}
+
+// handleGetCartRecommendations recommends products to add to a cart.
+//	@Summary		Get cart recommendations
+//	@Description	Recommend products frequently bought together with the products in a cart, best match first. Products already in the cart are left out.
+//	@Tags			catalog
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int									false	"Maximum number of products (default 10, at most 50)"
+//	@Param			payload	body		types.CartRecommendationsPayload	true	"Products in the cart"
+//	@Success		200		{array}		types.Product						"recommended products"
+//	@Failure		400		{object}	map[string]string					"invalid payload or limit"
+//	@Failure		500		{object}	map[string]string					"internal server error"
+//	@Router			/catalog/recommendations [post]
+func (h *Handler) handleGetCartRecommendations(c *gin.Context) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload types.CartRecommendationsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	h.writeRecommendations(c, payload.ProductIDs, limit)
+}
+
+// writeRecommendations responds with the recommended products for productIDs in score order
+func (h *Handler) writeRecommendations(c *gin.Context, productIDs []int, limit int) {
+	recommendations, err := h.store.GetRecommendations(productIDs, limit)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	products := make([]types.Product, 0, len(recommendations))
+	if len(recommendations) > 0 {
+		ids := make([]int, len(recommendations))
+		for i, r := range recommendations {
+			ids[i] = r.ProductID
+		}
+
+		found, err := h.productStore.GetProductsByIDs(ids)
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return
+		}
+		byID := make(map[int]types.Product, len(found))
+		for _, p := range found {
+			byID[p.ID] = p
+		}
+
+		for _, id := range ids {
+			if p, ok := byID[id]; ok {
+				products = append(products, p)
+			}
+		}
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, products)
+}
+
+// parseLimit reads the limit query parameter
+func parseLimit(c *gin.Context) (int, error) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,117 @@
+package recommendation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ComputeRecommendations rebuilds the recommendations from the paid orders. For every pair of
+// products bought together in at least minOrders orders, the score of the second for the first
+// is the share of the first product's orders that also contained the second. The table is
+// replaced in one transaction, so readers keep seeing the previous scores until it commits.
+// It returns how many recommendations were stored.
+func (s *Store) ComputeRecommendations(minOrders int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute recommendations: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_recommendations"); err != nil {
+		return 0, fmt.Errorf("failed to compute recommendations: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO product_recommendations (productId, relatedProductId, orderCount, score)
+		SELECT a.productId, b.productId, COUNT(DISTINCT a.orderId), COUNT(DISTINCT a.orderId) / totals.orderCount
+		FROM order_items a
+		JOIN order_items b ON b.orderId = a.orderId AND b.productId <> a.productId
+		JOIN orders o ON o.id = a.orderId
+		JOIN (
+			SELECT oi.productId, COUNT(DISTINCT oi.orderId) AS orderCount
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.orderId
+			WHERE o.status IN ('successful', 'delivered')
+			GROUP BY oi.productId
+		) totals ON totals.productId = a.productId
+		WHERE o.status IN ('successful', 'delivered')
+		GROUP BY a.productId, b.productId, totals.orderCount
+		HAVING COUNT(DISTINCT a.orderId) >= ?
+	`, minOrders)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute recommendations: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute recommendations: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to compute recommendations: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// GetRecommendations retrieves the products most often bought together with the given ones,
+// best first. Scores are added up over the given products, which are themselves left out,
+// and only published products are recommended.
+func (s *Store) GetRecommendations(productIDs []int, limit int) ([]types.Recommendation, error) {
+	recommendations := make([]types.Recommendation, 0)
+	if len(productIDs) == 0 {
+		return recommendations, nil
+	}
+
+	ctx := context.Background()
+
+	placeholders := make([]string, len(productIDs))
+	ids := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		placeholders[i] = "?"
+		ids[i] = id
+	}
+	in := strings.Join(placeholders, ",")
+
+	args := append(append(append([]interface{}{}, ids...), ids...), types.ProductStatusPublished, limit)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT r.relatedProductId, SUM(r.score) AS score
+		FROM product_recommendations r
+		JOIN products p ON p.id = r.relatedProductId
+		WHERE r.productId IN (%s) AND r.relatedProductId NOT IN (%s)
+			AND p.status = ? AND p.deletedAt IS NULL
+		GROUP BY r.relatedProductId
+		ORDER BY score DESC, r.relatedProductId
+		LIMIT ?
+	`, in, in), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r types.Recommendation
+		if err := rows.Scan(&r.ProductID, &r.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan recommendation: %w", err)
+		}
+		recommendations = append(recommendations, r)
+	}
+
+	return recommendations, rows.Err()
+}
@@ -0,0 +1,299 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+type Handler struct {
+	store        types.ReviewStore
+	productStore types.ProductStore
+}
+
+func NewHandler(store types.ReviewStore, productStore types.ProductStore) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	// Public route listing approved reviews of a catalog product
+	router.GET("/catalog/products/:id/reviews", h.handleGetProductReviews)
+
+	// Authenticated routes
+	router.POST("/catalog/products/:id/reviews", middleware.JWTAuth(), h.handleCreateReview)
+
+	reviewRouter := router.Group("/reviews")
+	reviewRouter.Use(middleware.JWTAuth())
+	reviewRouter.POST("/:id/helpful", h.handleVoteHelpful)
+
+	// Moderation routes
+	adminRouter := reviewRouter.Group("")
+	adminRouter.Use(middleware.RequirePermission(types.PermissionReviewsModerate))
+	adminRouter.GET("", h.handleGetReviewsByStatus)
+	adminRouter.PUT("/:id/status", h.handleUpdateReviewStatus)
+}
+
+// handleGetProductReviews lists the approved reviews of a product.
+//	@Summary		Get product reviews
+//	@Description	List the approved reviews of a product, most helpful first
+//	@Tags			reviews
+//	@Produce		json
+//	@Param			id	path		int					true	"Product ID"
+//	@Success		200	{array}		types.Review		"list of reviews"
+//	@Failure		400	{object}	map[string]string	"invalid product ID"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/catalog/products/{id}/reviews [get]
+func (h *Handler) handleGetProductReviews(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	reviews, err := h.store.GetApprovedReviewsByProductID(productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, reviews)
+}
+
+// handleCreateReview lets a customer review a product they received.
+//	@Summary		Review a product
+//	@Description	Leave a 1-5 star rating and review for a product from one of your delivered orders
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int							true	"Product ID"
+//	@Param			payload	body		types.CreateReviewPayload	true	"Review payload"
+//	@Success		201		{object}	types.Review				"created review, pending moderation"
+//	@Failure		400		{object}	map[string]string			"invalid product ID or payload"
+//	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		403		{object}	map[string]string			"product was not delivered to the user"
+//	@Failure		404		{object}	map[string]string			"product not found"
+//	@Failure		409		{object}	map[string]string			"product already reviewed"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/catalog/products/{id}/reviews [post]
+func (h *Handler) handleCreateReview(c *gin.Context) {
+	// Retrieve userID from the request context
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	var payload types.CreateReviewPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil || !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+
+	// Only customers who actually received the product may review it
+	delivered, err := h.store.HasDeliveredProduct(userID.(int), productID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	if !delivered {
+		utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("you can only review products from your delivered orders"))
+		return
+	}
+
+	reviewID, err := h.store.CreateReview(types.Review{
+		ProductID: productID,
+		UserID:    userID.(int),
+		Rating:    payload.Rating,
+		Title:     payload.Title,
+		Body:      payload.Body,
+	})
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	review, err := h.store.GetReviewByID(reviewID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Log the new review
+	utils.Log.WithFields(logrus.Fields{
+		"reviewID":  reviewID,
+		"productID": productID,
+		"userID":    userID,
+		"rating":    payload.Rating,
+	}).Info("New review submitted")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, review)
+}
+
+// handleVoteHelpful marks a review as helpful.
+//	@Summary		Vote a review helpful
+//	@Description	Mark an approved review as helpful; each user can vote once per review
+//	@Tags			reviews
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Review ID"
+//	@Success		200	{object}	types.Review		"updated review"
+//	@Failure		400	{object}	map[string]string	"invalid review ID or own review"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		404	{object}	map[string]string	"review not found"
+//	@Failure		409	{object}	map[string]string	"already voted"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/reviews/{id}/helpful [post]
+func (h *Handler) handleVoteHelpful(c *gin.Context) {
+	// Retrieve userID from the request context
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid review ID"))
+		return
+	}
+
+	review, err := h.store.GetReviewByID(reviewID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Hidden and pending reviews are not visible, so they can't be voted on either
+	if review.Status != "approved" {
+		utils.WriteError(c.Writer, http.StatusNotFound, ErrReviewNotFound)
+		return
+	}
+
+	if review.UserID == userID.(int) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("you can't vote for your own review"))
+		return
+	}
+
+	if err := h.store.AddHelpfulVote(reviewID, userID.(int)); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	review.HelpfulCount++
+
+	utils.WriteJSON(c.Writer, http.StatusOK, review)
+}
+
+// handleGetReviewsByStatus lists reviews for moderation.
+//	@Summary		Get reviews by status
+//	@Description	List reviews with the given moderation status, pending by default (requires reviews:moderate)
+//	@Tags			reviews
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			status	query		string				false	"Moderation status"	Enums(pending, approved, hidden)
+//	@Success		200		{array}		types.Review		"list of reviews"
+//	@Failure		400		{object}	map[string]string	"invalid status"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/reviews [get]
+func (h *Handler) handleGetReviewsByStatus(c *gin.Context) {
+	status := c.DefaultQuery("status", "pending")
+	if err := utils.Validate.Var(status, "oneof=pending approved hidden"); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid status %q", status))
+		return
+	}
+
+	reviews, err := h.store.GetReviewsByStatus(status)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, reviews)
+}
+
+// handleUpdateReviewStatus approves or hides a review.
+//	@Summary		Moderate a review
+//	@Description	Approve or hide a review (requires reviews:moderate)
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int								true	"Review ID"
+//	@Param			payload	body		types.UpdateReviewStatusPayload	true	"Review status payload"
+//	@Success		200		{object}	map[string]string				"status updated"
+//	@Failure		400		{object}	map[string]string				"invalid review ID or payload"
+//	@Failure		404		{object}	map[string]string				"review not found"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/reviews/{id}/status [put]
+func (h *Handler) handleUpdateReviewStatus(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid review ID"))
+		return
+	}
+
+	var payload types.UpdateReviewStatusPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	if err := h.store.UpdateReviewStatus(reviewID, payload.Status); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	// Log the moderation decision
+	utils.Log.WithFields(logrus.Fields{
+		"reviewID": reviewID,
+		"status":   payload.Status,
+	}).Info("Review moderated")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// writeStoreError maps review store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrReviewNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrReviewExists), errors.Is(err, ErrAlreadyVoted):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,218 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrReviewExists   = errors.New("you have already reviewed this product")
+	ErrAlreadyVoted   = errors.New("you have already voted for this review")
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// HasDeliveredProduct reports whether the user has a delivered order containing the product.
+func (s *Store) HasDeliveredProduct(userID, productID int) (bool, error) {
+	ctx := context.Background()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM orders o
+		JOIN order_items oi ON oi.orderId = o.id
+		WHERE o.userId = ? AND oi.productId = ? AND o.status = 'delivered'
+	`, userID, productID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check delivered orders: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// CreateReview stores a new review. Reviews start out pending until an admin approves them.
+func (s *Store) CreateReview(review types.Review) (int, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO reviews (productId, userId, rating, title, body)
+		VALUES (?, ?, ?, ?, ?)
+	`, review.ProductID, review.UserID, review.Rating, review.Title, review.Body)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return 0, ErrReviewExists
+		}
+		return 0, fmt.Errorf("failed to create review: %w", err)
+	}
+
+	reviewID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return int(reviewID), nil
+}
+
+// GetReviewByID retrieves a review by its ID.
+func (s *Store) GetReviewByID(id int) (*types.Review, error) {
+	ctx := context.Background()
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, productId, userId, rating, title, body, status, helpfulCount, createdAt
+		FROM reviews
+		WHERE id = ?
+	`, id)
+
+	review, err := scanReview(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReviewNotFound
+		}
+		return nil, fmt.Errorf("failed to scan review: %w", err)
+	}
+
+	return review, nil
+}
+
+// GetApprovedReviewsByProductID retrieves the approved reviews of a product, most helpful first.
+func (s *Store) GetApprovedReviewsByProductID(productID int) ([]types.Review, error) {
+	return s.queryReviews(`
+		SELECT id, productId, userId, rating, title, body, status, helpfulCount, createdAt
+		FROM reviews
+		WHERE productId = ? AND status = 'approved'
+		ORDER BY helpfulCount DESC, createdAt DESC
+	`, productID)
+}
+
+// GetReviewsByStatus retrieves all reviews with the given moderation status, oldest first.
+func (s *Store) GetReviewsByStatus(status string) ([]types.Review, error) {
+	return s.queryReviews(`
+		SELECT id, productId, userId, rating, title, body, status, helpfulCount, createdAt
+		FROM reviews
+		WHERE status = ?
+		ORDER BY createdAt ASC
+	`, status)
+}
+
+// UpdateReviewStatus moderates a review and refreshes the rating aggregate of its product.
+func (s *Store) UpdateReviewStatus(reviewID int, status string) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update review status: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID int
+	if err := tx.QueryRowContext(ctx, "SELECT productId FROM reviews WHERE id = ? FOR UPDATE", reviewID).Scan(&productID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrReviewNotFound
+		}
+		return fmt.Errorf("failed to update review status: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE reviews SET status = ? WHERE id = ?", status, reviewID); err != nil {
+		return fmt.Errorf("failed to update review status: %w", err)
+	}
+
+	// Only approved reviews count towards the rating shown in the catalog
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE products
+		SET ratingAverage = (SELECT COALESCE(AVG(rating), 0) FROM reviews WHERE productId = ? AND status = 'approved'),
+			ratingCount = (SELECT COUNT(*) FROM reviews WHERE productId = ? AND status = 'approved')
+		WHERE id = ?
+	`, productID, productID, productID); err != nil {
+		return fmt.Errorf("failed to update product rating: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// AddHelpfulVote records that a user found a review helpful. Each user can vote once per review.
+func (s *Store) AddHelpfulVote(reviewID, userID int) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to add vote: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO review_votes (reviewId, userId) VALUES (?, ?)", reviewID, userID); err != nil {
+		if isDuplicateEntry(err) {
+			return ErrAlreadyVoted
+		}
+		return fmt.Errorf("failed to add vote: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE reviews SET helpfulCount = helpfulCount + 1 WHERE id = ?", reviewID); err != nil {
+		return fmt.Errorf("failed to add vote: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// queryReviews runs a review query and scans every row.
+func (s *Store) queryReviews(query string, args ...interface{}) ([]types.Review, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := make([]types.Review, 0)
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, *review)
+	}
+
+	return reviews, nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReview(row scanner) (*types.Review, error) {
+	var review types.Review
+	if err := row.Scan(
+		&review.ID,
+		&review.ProductID,
+		&review.UserID,
+		&review.Rating,
+		&review.Title,
+		&review.Body,
+		&review.Status,
+		&review.HelpfulCount,
+		&review.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique key violation.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
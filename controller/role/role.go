@@ -0,0 +1,252 @@
+package role
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/controller/audit"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// namePattern matches role names: lowercase letters, digits and underscores
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type Handler struct {
+	store types.RoleStore
+	audit types.AuditStore
+}
+
+func NewHandler(store types.RoleStore, auditStore types.AuditStore) *Handler {
+	return &Handler{
+		store: store,
+		audit: auditStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/permissions", middleware.JWTAuth(), middleware.RequirePermission(types.PermissionRolesManage), h.handleGetPermissions)
+
+	roleRouter := router.Group("/roles")
+	roleRouter.Use(middleware.JWTAuth(), middleware.RequirePermission(types.PermissionRolesManage))
+	roleRouter.GET("", h.handleGetRoles)
+	roleRouter.POST("", h.handleCreateRole)
+	roleRouter.GET("/:name", h.handleGetRole)
+	roleRouter.PUT("/:name", h.handleUpdateRole)
+	roleRouter.DELETE("/:name", h.handleDeleteRole)
+}
+
+// handleGetPermissions lists the permissions roles can be granted.
+//	@Summary		Get permissions
+//	@Description	List the permissions roles can be granted (requires roles:manage)
+//	@Tags			roles
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.Permission	"list of permissions"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/permissions [get]
+func (h *Handler) handleGetPermissions(c *gin.Context) {
+	permissions, err := h.store.GetPermissions()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, permissions)
+}
+
+// handleGetRoles lists all roles.
+//	@Summary		Get roles
+//	@Description	List the roles users can have, with their permissions (requires roles:manage)
+//	@Tags			roles
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.Role			"list of roles"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/roles [get]
+func (h *Handler) handleGetRoles(c *gin.Context) {
+	roles, err := h.store.GetRoles()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, roles)
+}
+
+// handleGetRole gets a role.
+//	@Summary		Get a role
+//	@Description	Get a role with its permissions (requires roles:manage)
+//	@Tags			roles
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			name	path		string				true	"Role name"
+//	@Success		200		{object}	types.Role			"role"
+//	@Failure		404		{object}	map[string]string	"role not found"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/roles/{name} [get]
+func (h *Handler) handleGetRole(c *gin.Context) {
+	role, err := h.store.GetRole(c.Param("name"))
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, role)
+}
+
+// handleCreateRole creates a role.
+//	@Summary		Create a role
+//	@Description	Create a staff role with the given permissions (requires roles:manage)
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.CreateRolePayload	true	"Role payload"
+//	@Success		201		{object}	types.Role				"created role"
+//	@Failure		400		{object}	map[string]string		"invalid payload or unknown permission"
+//	@Failure		409		{object}	map[string]string		"role already exists"
+//	@Failure		500		{object}	map[string]string		"internal server error"
+//	@Router			/roles [post]
+func (h *Handler) handleCreateRole(c *gin.Context) {
+	var payload types.CreateRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+	if !namePattern.MatchString(payload.Name) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: role names may only contain lowercase letters, digits and underscores"))
+		return
+	}
+
+	if err := h.store.CreateRole(types.Role{
+		Name:        payload.Name,
+		Description: payload.Description,
+		Permissions: payload.Permissions,
+	}); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	role, err := h.store.GetRole(payload.Name)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "role.create", "role", 0, map[string]any{"role": role.Name, "permissions": role.Permissions})
+
+	utils.Log.WithFields(logrus.Fields{
+		"role":        role.Name,
+		"permissions": role.Permissions,
+	}).Info("Role created")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, role)
+}
+
+// handleUpdateRole changes the permissions of a role.
+//	@Summary		Update a role
+//	@Description	Change the description of a role and replace its permissions (requires roles:manage). Users with the role get the new permissions right away. Built-in roles can't be changed.
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			name	path		string					true	"Role name"
+//	@Param			payload	body		types.UpdateRolePayload	true	"Role payload"
+//	@Success		200		{object}	types.Role				"updated role"
+//	@Failure		400		{object}	map[string]string		"invalid payload or unknown permission"
+//	@Failure		404		{object}	map[string]string		"role not found"
+//	@Failure		409		{object}	map[string]string		"built-in role"
+//	@Failure		500		{object}	map[string]string		"internal server error"
+//	@Router			/roles/{name} [put]
+func (h *Handler) handleUpdateRole(c *gin.Context) {
+	var payload types.UpdateRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	name := c.Param("name")
+	if err := h.store.UpdateRole(types.Role{
+		Name:        name,
+		Description: payload.Description,
+		Permissions: payload.Permissions,
+	}); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	role, err := h.store.GetRole(name)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "role.update", "role", 0, map[string]any{"role": role.Name, "permissions": role.Permissions})
+
+	utils.Log.WithFields(logrus.Fields{
+		"role":        role.Name,
+		"permissions": role.Permissions,
+	}).Info("Role updated")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, role)
+}
+
+// handleDeleteRole deletes a role.
+//	@Summary		Delete a role
+//	@Description	Delete a role no user has (requires roles:manage). Pending invitations to the role are deleted with it. Built-in roles can't be deleted.
+//	@Tags			roles
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			name	path	string	true	"Role name"
+//	@Success		204		"no content"
+//	@Failure		404		{object}	map[string]string	"role not found"
+//	@Failure		409		{object}	map[string]string	"built-in role or role assigned to users"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/roles/{name} [delete]
+func (h *Handler) handleDeleteRole(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.store.DeleteRole(name); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "role.delete", "role", 0, map[string]any{"role": name})
+
+	utils.Log.WithFields(logrus.Fields{
+		"role": name,
+	}).Info("Role deleted")
+
+	utils.WriteJSON(c.Writer, http.StatusNoContent, nil)
+}
+
+// writeStoreError maps role store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrRoleNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrUnknownPermission):
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+	case errors.Is(err, ErrRoleExists), errors.Is(err, ErrRoleBuiltIn), errors.Is(err, ErrRoleInUse):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
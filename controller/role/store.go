@@ -0,0 +1,259 @@
+package role
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("a role with this name already exists")
+	ErrRoleBuiltIn       = errors.New("built-in roles can't be changed or deleted")
+	ErrRoleInUse         = errors.New("role is assigned to users")
+	ErrUnknownPermission = errors.New("unknown permission")
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetRoles retrieves all roles with their permissions, ordered by name.
+func (s *Store) GetRoles() ([]types.Role, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT name, description, builtIn, createdAt FROM roles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make([]types.Role, 0)
+	for rows.Next() {
+		r := types.Role{Permissions: make([]string, 0)}
+		if err := rows.Scan(&r.Name, &r.Description, &r.BuiltIn, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.db.QueryContext(ctx, "SELECT role, permission FROM role_permissions ORDER BY permission")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role permissions: %w", err)
+	}
+	defer permissions.Close()
+
+	byName := make(map[string]*types.Role, len(roles))
+	for i := range roles {
+		byName[roles[i].Name] = &roles[i]
+	}
+	for permissions.Next() {
+		var role, permission string
+		if err := permissions.Scan(&role, &permission); err != nil {
+			return nil, fmt.Errorf("failed to scan role permission: %w", err)
+		}
+		if r, ok := byName[role]; ok {
+			r.Permissions = append(r.Permissions, permission)
+		}
+	}
+
+	return roles, permissions.Err()
+}
+
+// GetRole retrieves a role with its permissions.
+func (s *Store) GetRole(name string) (*types.Role, error) {
+	ctx := context.Background()
+
+	r := types.Role{Permissions: make([]string, 0)}
+	err := s.db.QueryRowContext(ctx, "SELECT name, description, builtIn, createdAt FROM roles WHERE name = ?", name).
+		Scan(&r.Name, &r.Description, &r.BuiltIn, &r.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("failed to scan role permission: %w", err)
+		}
+		r.Permissions = append(r.Permissions, permission)
+	}
+
+	return &r, rows.Err()
+}
+
+// CreateRole stores a new role with its permissions.
+func (s *Store) CreateRole(r types.Role) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO roles (name, description) VALUES (?, ?)", r.Name, r.Description); err != nil {
+		if isDuplicateEntry(err) {
+			return ErrRoleExists
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	if err := insertPermissions(ctx, tx, r.Name, r.Permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateRole changes the description of a role and replaces its permissions. Users with the
+// role get the new permissions on their next request.
+func (s *Store) UpdateRole(r types.Role) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var builtIn bool
+	err = tx.QueryRowContext(ctx, "SELECT builtIn FROM roles WHERE name = ? FOR UPDATE", r.Name).Scan(&builtIn)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRoleNotFound
+		}
+		return fmt.Errorf("failed to get role: %w", err)
+	}
+	if builtIn {
+		return ErrRoleBuiltIn
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE roles SET description = ? WHERE name = ?", r.Description, r.Name); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = ?", r.Name); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+	if err := insertPermissions(ctx, tx, r.Name, r.Permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRole deletes a role that no user has. Pending invitations to the role are deleted with it.
+func (s *Store) DeleteRole(name string) error {
+	ctx := context.Background()
+
+	role, err := s.GetRole(name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM roles WHERE name = ?", name); err != nil {
+		if isForeignKeyViolation(err, mysqlRowIsReferenced) {
+			return ErrRoleInUse
+		}
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	return nil
+}
+
+// GetPermissions retrieves all permissions roles can be granted, ordered by name.
+func (s *Store) GetPermissions() ([]types.Permission, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT name, description FROM permissions ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := make([]types.Permission, 0)
+	for rows.Next() {
+		var p types.Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, p)
+	}
+
+	return permissions, rows.Err()
+}
+
+// RoleHasPermission reports whether the role has been granted the permission.
+func (s *Store) RoleHasPermission(role, permission string) (bool, error) {
+	ctx := context.Background()
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = ? AND permission = ?)
+	`, role, permission).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check role permission: %w", err)
+	}
+
+	return exists, nil
+}
+
+// insertPermissions grants permissions to a role.
+func insertPermissions(ctx context.Context, tx *sql.Tx, role string, permissions []string) error {
+	seen := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO role_permissions (role, permission) VALUES (?, ?)", role, permission); err != nil {
+			if isForeignKeyViolation(err, mysqlNoReferencedRow) {
+				return fmt.Errorf("%w: %s", ErrUnknownPermission, permission)
+			}
+			return fmt.Errorf("failed to grant permission: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MySQL foreign key violations
+const (
+	mysqlRowIsReferenced = 1451 // a row that is referenced is deleted
+	mysqlNoReferencedRow = 1452 // a row references one that doesn't exist
+)
+
+func isForeignKeyViolation(err error, number uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique key violation.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
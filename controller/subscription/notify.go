@@ -0,0 +1,83 @@
+package subscription
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// Notifier fulfills the stock subscriptions of a product once it is back in stock.
+type Notifier struct {
+	store  types.StockSubscriptionStore
+	sender notification.Sender
+}
+
+func NewNotifier(store types.StockSubscriptionStore, sender notification.Sender) *Notifier {
+	return &Notifier{
+		store:  store,
+		sender: sender,
+	}
+}
+
+// NotifyRestocked notifies every user subscribed to the product. A subscription is marked as
+// fulfilled before its notification is sent so concurrent restocks don't notify anyone twice.
+// When sending fails, e.g. because the user hit the rate limit, the subscription is renewed
+// so the user hears about the next restock instead.
+func (n *Notifier) NotifyRestocked(product types.Product) {
+	if !product.IsAvailable() {
+		return
+	}
+
+	users, err := n.store.GetStockSubscribers(product.ID)
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"productID": product.ID,
+			"error":     err,
+		}).Error("Failed to look up stock subscriptions")
+		return
+	}
+
+	for _, user := range users {
+		claimed, err := n.store.MarkStockSubscriptionNotified(product.ID, user.ID)
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"productID": product.ID,
+				"userID":    user.ID,
+				"error":     err,
+			}).Error("Failed to update stock subscription")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		err = n.sender.Send(notification.Message{
+			To:      user.Email,
+			Subject: fmt.Sprintf("%s is back in stock", product.Name),
+			Body:    fmt.Sprintf("Hi %s, %s is back in stock. Order now before it runs out again.", user.FirstName, product.Name),
+		})
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"productID": product.ID,
+				"userID":    user.ID,
+				"error":     err,
+			}).Warn("Failed to send back-in-stock notification")
+			if err := n.store.SubscribeToStock(product.ID, user.ID); err != nil {
+				utils.Log.WithFields(logrus.Fields{
+					"productID": product.ID,
+					"userID":    user.ID,
+					"error":     err,
+				}).Error("Failed to renew stock subscription")
+			}
+			continue
+		}
+
+		utils.Log.WithFields(logrus.Fields{
+			"productID": product.ID,
+			"userID":    user.ID,
+		}).Info("Stock subscription fulfilled")
+	}
+}
@@ -0,0 +1,137 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetStockSubscriptionsByUserID retrieves the stock subscriptions of a user, newest first.
+func (s *Store) GetStockSubscriptionsByUserID(userID int) ([]types.StockSubscription, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT productId, userId, notifiedAt, createdAt
+		FROM stock_subscriptions
+		WHERE userId = ?
+		ORDER BY createdAt DESC, productId
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]types.StockSubscription, 0)
+	for rows.Next() {
+		var subscription types.StockSubscription
+		var notifiedAt sql.NullTime
+		if err := rows.Scan(&subscription.ProductID, &subscription.UserID, &notifiedAt, &subscription.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		if notifiedAt.Valid {
+			subscription.NotifiedAt = &notifiedAt.Time
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// SubscribeToStock subscribes a user to a product. Subscribing again renews a subscription
+// that was already fulfilled.
+func (s *Store) SubscribeToStock(productID, userID int) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO stock_subscriptions (productId, userId)
+		VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE notifiedAt = NULL, createdAt = CURRENT_TIMESTAMP
+	`, productID, userID); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	return nil
+}
+
+// UnsubscribeFromStock removes the subscription of a user to a product.
+func (s *Store) UnsubscribeFromStock(productID, userID int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM stock_subscriptions WHERE productId = ? AND userId = ?", productID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+	if n == 0 {
+		return ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// GetStockSubscribers retrieves the users waiting to hear that a product is back in stock.
+func (s *Store) GetStockSubscribers(productID int) ([]types.User, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.id, u.firstName, u.lastName, u.email
+		FROM stock_subscriptions ss
+		JOIN users u ON u.id = ss.userId
+		WHERE ss.productId = ? AND ss.notifiedAt IS NULL
+		ORDER BY ss.createdAt, u.id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]types.User, 0)
+	for rows.Next() {
+		var u types.User
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// MarkStockSubscriptionNotified marks a pending subscription as fulfilled. It returns false
+// when the subscription was already fulfilled or removed, so only one caller notifies the user.
+func (s *Store) MarkStockSubscriptionNotified(productID, userID int) (bool, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE stock_subscriptions
+		SET notifiedAt = CURRENT_TIMESTAMP
+		WHERE productId = ? AND userId = ? AND notifiedAt IS NULL
+	`, productID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	return n > 0, nil
+}
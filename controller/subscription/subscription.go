@@ -0,0 +1,161 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+type Handler struct {
+	store        types.StockSubscriptionStore
+	productStore types.ProductStore
+}
+
+func NewHandler(store types.StockSubscriptionStore, productStore types.ProductStore) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	subscriptionRouter := router.Group("/subscriptions")
+	subscriptionRouter.Use(middleware.JWTAuth())
+	subscriptionRouter.GET("", h.handleGetSubscriptions)
+	subscriptionRouter.POST("", h.handleSubscribe)
+	subscriptionRouter.DELETE("/:productId", h.handleUnsubscribe)
+}
+
+// handleGetSubscriptions lists the stock subscriptions of the authenticated user.
+//	@Summary		Get stock subscriptions
+//	@Description	List the products the authenticated user asked to be notified about. Fulfilled subscriptions have notifiedAt set.
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.StockSubscription	"subscriptions"
+//	@Failure		401	{object}	map[string]string		"unauthorized"
+//	@Failure		500	{object}	map[string]string		"internal server error"
+//	@Router			/subscriptions [get]
+func (h *Handler) handleGetSubscriptions(c *gin.Context) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	subscriptions, err := h.store.GetStockSubscriptionsByUserID(userID.(int))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, subscriptions)
+}
+
+// handleSubscribe asks to be notified when a product is back in stock.
+//	@Summary		Notify me when back in stock
+//	@Description	Subscribe the authenticated user to an out-of-stock product. They are notified once when its quantity goes above zero again, after which they can subscribe again.
+//	@Tags			subscriptions
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.StockSubscriptionPayload	true	"Subscription payload"
+//	@Success		201		{object}	map[string]string				"message"
+//	@Failure		400		{object}	map[string]string				"invalid payload"
+//	@Failure		401		{object}	map[string]string				"unauthorized"
+//	@Failure		404		{object}	map[string]string				"product not found"
+//	@Failure		409		{object}	map[string]string				"product is in stock"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/subscriptions [post]
+func (h *Handler) handleSubscribe(c *gin.Context) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	var payload types.StockSubscriptionPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(payload.ProductID)
+	if err != nil || !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+	if product.IsDigital || product.Quantity > 0 {
+		utils.WriteError(c.Writer, http.StatusConflict, fmt.Errorf("product %d is in stock", product.ID))
+		return
+	}
+
+	if err := h.store.SubscribeToStock(product.ID, userID.(int)); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"productID": product.ID,
+		"userID":    userID,
+	}).Info("Subscribed to stock")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, map[string]string{"message": "you will be notified when the product is back in stock"})
+}
+
+// handleUnsubscribe cancels a stock subscription.
+//	@Summary		Cancel a stock subscription
+//	@Description	Stop waiting for a product to come back in stock
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			productId	path		int					true	"Product ID"
+//	@Success		200			{object}	map[string]string	"message"
+//	@Failure		400			{object}	map[string]string	"invalid product ID"
+//	@Failure		401			{object}	map[string]string	"unauthorized"
+//	@Failure		404			{object}	map[string]string	"subscription not found"
+//	@Failure		500			{object}	map[string]string	"internal server error"
+//	@Router			/subscriptions/{productId} [delete]
+func (h *Handler) handleUnsubscribe(c *gin.Context) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	productID, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	if err := h.store.UnsubscribeFromStock(productID, userID.(int)); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "subscription cancelled"})
+}
+
+// writeStoreError maps subscription store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
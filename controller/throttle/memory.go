@@ -0,0 +1,88 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+// pruneInterval is how often MemoryStore drops entries whose failures have been forgotten
+const pruneInterval = time.Minute
+
+// MemoryStore keeps failed login attempts in memory. Limits are per server and reset on
+// restart, which suits a single server or development.
+type MemoryStore struct {
+	mu        sync.Mutex
+	throttles map[string]*types.LoginThrottle
+	prunedAt  time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		throttles: make(map[string]*types.LoginThrottle),
+		prunedAt:  time.Now(),
+	}
+}
+
+func (s *MemoryStore) ReserveLoginAttempt(key string, since time.Time, backoff func(failures int) time.Duration) (int, *time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(since, now)
+
+	throttle, ok := s.throttles[key]
+	if ok && throttle.BlockedUntil != nil && throttle.BlockedUntil.After(now) {
+		blockedUntil := *throttle.BlockedUntil
+		return throttle.Failures, &blockedUntil, nil
+	}
+	if !ok || throttle.LastFailureAt.Before(since) {
+		throttle = &types.LoginThrottle{Key: key}
+		s.throttles[key] = throttle
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	throttle.BlockedUntil = nil
+	if d := backoff(throttle.Failures); d > 0 {
+		until := now.Add(d)
+		throttle.BlockedUntil = &until
+	}
+
+	return throttle.Failures, nil, nil
+}
+
+func (s *MemoryStore) ReleaseLoginAttempt(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if throttle, ok := s.throttles[key]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ClearLoginFailures(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.throttles, key)
+
+	return nil
+}
+
+// prune drops entries whose failures are older than since and that are no longer blocked,
+// at most once per pruneInterval. The caller holds the lock.
+func (s *MemoryStore) prune(since, now time.Time) {
+	if now.Sub(s.prunedAt) < pruneInterval {
+		return
+	}
+	s.prunedAt = now
+
+	for key, throttle := range s.throttles {
+		if throttle.LastFailureAt.Before(since) && (throttle.BlockedUntil == nil || throttle.BlockedUntil.Before(now)) {
+			delete(s.throttles, key)
+		}
+	}
+}
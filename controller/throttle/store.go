@@ -0,0 +1,107 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Store keeps failed login attempts in MySQL, so limits hold across servers and restarts.
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ReserveLoginAttempt counts an attempt for key as a failure, unless key is blocked. Failures
+// before the given time are forgotten. When backoff returns a positive duration for the new
+// number of failures, key is blocked for that long straight away. The row is locked throughout,
+// so concurrent attempts are counted one after the other. It returns the number of failures,
+// and the time key is blocked until if the attempt is refused.
+func (s *Store) ReserveLoginAttempt(key string, since time.Time, backoff func(failures int) time.Duration) (int, *time.Time, error) {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	// Make sure there is a row to lock
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO login_throttles (throttleKey, failures, lastFailureAt)
+		VALUES (?, 0, ?)
+		ON DUPLICATE KEY UPDATE throttleKey = throttleKey
+	`, key, now); err != nil {
+		return 0, nil, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	var failures int
+	var lastFailureAt time.Time
+	var blockedUntil sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT failures, lastFailureAt, blockedUntil
+		FROM login_throttles
+		WHERE throttleKey = ?
+		FOR UPDATE
+	`, key).Scan(&failures, &lastFailureAt, &blockedUntil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	if blockedUntil.Valid && blockedUntil.Time.After(now) {
+		return failures, &blockedUntil.Time, nil
+	}
+
+	if lastFailureAt.Before(since) {
+		failures = 0
+	}
+	failures++
+
+	var until sql.NullTime
+	if d := backoff(failures); d > 0 {
+		until = sql.NullTime{Time: now.Add(d), Valid: true}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE login_throttles
+		SET failures = ?, lastFailureAt = ?, blockedUntil = ?
+		WHERE throttleKey = ?
+	`, failures, now, until, key); err != nil {
+		return 0, nil, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	return failures, nil, nil
+}
+
+// ReleaseLoginAttempt takes back an attempt counted by ReserveLoginAttempt that succeeded.
+// A block it caused stays in place.
+func (s *Store) ReleaseLoginAttempt(key string) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE login_throttles SET failures = failures - 1 WHERE throttleKey = ? AND failures > 0", key); err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// ClearLoginFailures forgets the failed attempts of key, lifting any block.
+func (s *Store) ClearLoginFailures(key string) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM login_throttles WHERE throttleKey = ?", key); err != nil {
+		return fmt.Errorf("failed to clear login failures: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/controller/audit"
+	"github.com/youngprinnce/go-ecom/controller/role"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// targetUser loads the user an admin route acts on.
+func (h *Handler) targetUser(c *gin.Context) (*types.User, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid user ID"))
+		return nil, false
+	}
+
+	u, err := h.store.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("user not found"))
+		return nil, false
+	}
+
+	return u, true
+}
+
+// roleExists checks that users can be given the role, writing an error response if they can't.
+func (h *Handler) roleExists(c *gin.Context, name string) bool {
+	if _, err := h.roles.GetRole(name); err != nil {
+		if errors.Is(err, role.ErrRoleNotFound) {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("unknown role %q", name))
+		} else {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		}
+		return false
+	}
+	return true
+}
+
+// callerPermissions lists the permissions of whoever is making the request: the scopes of an
+// API key, or the permissions of the user's role.
+func (h *Handler) callerPermissions(c *gin.Context) (map[string]bool, error) {
+	if key, ok := c.Get(string(middleware.APIKeyKey)); ok {
+		return permissionSet(key.(*types.APIKey).Scopes), nil
+	}
+
+	r, err := h.roles.GetRole(c.GetString(string(middleware.RoleKey)))
+	if err != nil {
+		return nil, err
+	}
+	return permissionSet(r.Permissions), nil
+}
+
+// outranks checks that the caller holds every permission of a role, so staff can't act on
+// accounts more privileged than their own. It writes an error response if they don't.
+func (h *Handler) outranks(c *gin.Context, name string, denied error) bool {
+	held, err := h.callerPermissions(c)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return false
+	}
+
+	r, err := h.roles.GetRole(name)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return false
+	}
+
+	for _, permission := range r.Permissions {
+		if !held[permission] {
+			utils.WriteError(c.Writer, http.StatusForbidden, denied)
+			return false
+		}
+	}
+	return true
+}
+
+func permissionSet(permissions []string) map[string]bool {
+	set := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		set[permission] = true
+	}
+	return set
+}
+
+// handleSearchUsers lists users for admins.
+//	@Summary		Search users
+//	@Description	List users, newest first, optionally matching a name or email, a role or a status (requires users:read)
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			q		query		string				false	"Part of a name or email"
+//	@Param			role	query		string				false	"Role"
+//	@Param			status	query		string				false	"Account status"	Enums(active, disabled)
+//	@Param			page	query		int					false	"Page, from 1"
+//	@Param			perPage	query		int					false	"Users per page (default 20, at most 100)"
+//	@Success		200		{object}	types.UserPage		"page of users"
+//	@Failure		400		{object}	map[string]string	"invalid query"
+//	@Failure		500		{object}	map[string]string	"internal server error"
+//	@Router			/users [get]
+func (h *Handler) handleSearchUsers(c *gin.Context) {
+	query := types.UserSearchQuery{
+		Query:   c.Query("q"),
+		Role:    c.Query("role"),
+		Status:  c.Query("status"),
+		Page:    1,
+		PerPage: defaultPerPage,
+	}
+
+	if err := utils.Validate.Var(query.Status, "omitempty,oneof=active disabled"); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid status %q", query.Status))
+		return
+	}
+
+	var err error
+	if raw := c.Query("page"); raw != "" {
+		if query.Page, err = strconv.Atoi(raw); err != nil || query.Page < 1 {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("page must be a positive number"))
+			return
+		}
+	}
+	if raw := c.Query("perPage"); raw != "" {
+		if query.PerPage, err = strconv.Atoi(raw); err != nil || query.PerPage < 1 || query.PerPage > maxPerPage {
+			utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("perPage must be between 1 and %d", maxPerPage))
+			return
+		}
+	}
+
+	page, err := h.store.SearchUsers(query)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, page)
+}
+
+// handleGetUser returns a user for admins.
+//	@Summary		Get a user
+//	@Description	Get a user by ID (requires users:read)
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"User ID"
+//	@Success		200	{object}	types.User			"user"
+//	@Failure		400	{object}	map[string]string	"invalid user ID"
+//	@Failure		404	{object}	map[string]string	"user not found"
+//	@Router			/users/{id} [get]
+func (h *Handler) handleGetUser(c *gin.Context) {
+	u, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, u)
+}
+
+// handleDisableUser disables an account.
+//	@Summary		Disable a user
+//	@Description	Disable an account (requires users:write, and every permission of the user's role). Disabled users can't log in, and their sessions are revoked.
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"User ID"
+//	@Success		200	{object}	types.User			"disabled user"
+//	@Failure		400	{object}	map[string]string	"invalid user ID, or own account"
+//	@Failure		403	{object}	map[string]string	"user with permissions the caller doesn't have"
+//	@Failure		404	{object}	map[string]string	"user not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/{id}/disable [post]
+func (h *Handler) handleDisableUser(c *gin.Context) {
+	h.setUserDisabled(c, true)
+}
+
+// handleEnableUser enables a disabled account.
+//	@Summary		Enable a user
+//	@Description	Enable a disabled account again (requires users:write, and every permission of the user's role)
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"User ID"
+//	@Success		200	{object}	types.User			"enabled user"
+//	@Failure		400	{object}	map[string]string	"invalid user ID, or own account"
+//	@Failure		403	{object}	map[string]string	"user with permissions the caller doesn't have"
+//	@Failure		404	{object}	map[string]string	"user not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/{id}/enable [post]
+func (h *Handler) handleEnableUser(c *gin.Context) {
+	h.setUserDisabled(c, false)
+}
+
+func (h *Handler) setUserDisabled(c *gin.Context, disabled bool) {
+	u, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	// Admins can't lock themselves out
+	if u.ID == c.GetInt(string(middleware.UserKey)) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("you can't disable or enable your own account"))
+		return
+	}
+	if !h.outranks(c, u.Role, fmt.Errorf("you can't disable or enable users with permissions you don't have")) {
+		return
+	}
+
+	if err := h.store.SetUserDisabled(u.ID, disabled); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	action := "user.enable"
+	if disabled {
+		action = "user.disable"
+	}
+	audit.Record(c, h.audit, action, "user", u.ID, nil)
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID":   u.ID,
+		"disabled": disabled,
+	}).Info("User status changed")
+
+	updated, err := h.store.GetUserByID(u.ID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, updated)
+}
+
+// handleUpdateUserRole changes the role of a user.
+//	@Summary		Change the role of a user
+//	@Description	Change the role of a user (requires roles:manage, and every permission of both the user's role and the new one). The user's sessions are revoked, so the new role applies from the next login.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int							true	"User ID"
+//	@Param			payload	body		types.UpdateUserRolePayload	true	"Role payload"
+//	@Success		200		{object}	types.User					"updated user"
+//	@Failure		400		{object}	map[string]string			"invalid payload or unknown role, or own account"
+//	@Failure		403		{object}	map[string]string			"user or role with permissions the caller doesn't have"
+//	@Failure		404		{object}	map[string]string			"user not found"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/{id}/role [put]
+func (h *Handler) handleUpdateUserRole(c *gin.Context) {
+	var payload types.UpdateUserRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	// Admins can't demote themselves, which could leave the store without an admin
+	if u.ID == c.GetInt(string(middleware.UserKey)) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("you can't change your own role"))
+		return
+	}
+
+	if !h.roleExists(c, payload.Role) {
+		return
+	}
+
+	// Staff can only move users between roles they hold every permission of
+	if !h.outranks(c, u.Role, fmt.Errorf("you can't change the role of users with permissions you don't have")) {
+		return
+	}
+	if !h.outranks(c, payload.Role, fmt.Errorf("you can't give users the %q role, it has permissions you don't have", payload.Role)) {
+		return
+	}
+
+	if u.Role != payload.Role {
+		if err := h.store.SetUserRole(u.ID, payload.Role); err != nil {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return
+		}
+
+		audit.Record(c, h.audit, "user.role_change", "user", u.ID, map[string]any{"from": u.Role, "to": payload.Role})
+		u.Role = payload.Role
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, u)
+}
+
+// handleForcePasswordReset makes a user choose a new password.
+//	@Summary		Force a password reset
+//	@Description	Clear the password of a user, log them out everywhere and email them a password reset token (requires users:write, and every permission of the user's role). The account can't be used until the password is reset.
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"User ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"invalid user ID"
+//	@Failure		403	{object}	map[string]string	"user with permissions the caller doesn't have"
+//	@Failure		404	{object}	map[string]string	"user not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/{id}/password-reset [post]
+func (h *Handler) handleForcePasswordReset(c *gin.Context) {
+	u, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if !h.outranks(c, u.Role, fmt.Errorf("you can't reset the password of users with permissions you don't have")) {
+		return
+	}
+
+	if err := h.store.ForcePasswordReset(u.ID); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "user.password_reset_forced", "user", u.ID, nil)
+
+	if err := h.sendPasswordReset(h.mailer, u, "An administrator has reset the password of your account, so you need to choose a new one before you can log in again."); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "password reset, the user has been emailed a reset token"})
+}
@@ -0,0 +1,232 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/audit"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// handleGetInvitations lists the invitations that can still be accepted.
+//	@Summary		Get pending invitations
+//	@Description	List the staff invitations that haven't been accepted, revoked or expired (requires roles:manage)
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.UserInvitation	"pending invitations"
+//	@Failure		500	{object}	map[string]string		"internal server error"
+//	@Router			/users/invitations [get]
+func (h *Handler) handleGetInvitations(c *gin.Context) {
+	invitations, err := h.store.GetPendingInvitations()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, invitations)
+}
+
+// handleCreateInvitation invites someone to create a staff account.
+//	@Summary		Invite a staff member
+//	@Description	Invite someone by email to create a staff account with the given role, admin by default (requires roles:manage, and every permission of the role). They are sent a signed token that accepts the invitation until it expires.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.InviteUserPayload	true	"Invitation payload"
+//	@Success		201		{object}	types.UserInvitation	"created invitation"
+//	@Failure		400		{object}	map[string]string		"invalid payload or unknown role"
+//	@Failure		403		{object}	map[string]string		"role with permissions the caller doesn't have"
+//	@Failure		409		{object}	map[string]string		"user already exists"
+//	@Failure		500		{object}	map[string]string		"internal server error"
+//	@Router			/users/invitations [post]
+func (h *Handler) handleCreateInvitation(c *gin.Context) {
+	var payload types.InviteUserPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	// Invitations are for staff, customers register themselves
+	if payload.Role == "" {
+		payload.Role = types.RoleAdmin
+	}
+	if payload.Role == types.RoleUser {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invitations are for staff roles, customers can register themselves"))
+		return
+	}
+	if !h.roleExists(c, payload.Role) {
+		return
+	}
+	if !h.outranks(c, payload.Role, fmt.Errorf("you can't invite users to the %q role, it has permissions you don't have", payload.Role)) {
+		return
+	}
+
+	if _, err := h.store.GetUserByEmail(payload.Email); err == nil {
+		utils.WriteError(c.Writer, http.StatusConflict, ErrUserExists)
+		return
+	}
+
+	// Invitations sent with an API key aren't from anyone
+	var invitedBy *int
+	if adminID, exists := c.Get(string(middleware.UserKey)); exists {
+		id := adminID.(int)
+		invitedBy = &id
+	}
+	invitation := types.UserInvitation{
+		Email:     payload.Email,
+		Role:      payload.Role,
+		InvitedBy: invitedBy,
+		ExpiresAt: time.Now().Add(time.Duration(config.Envs.INVITATION_TTL_SECONDS) * time.Second).Truncate(time.Second),
+	}
+	invitationID, err := h.store.CreateInvitation(invitation)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	token, err := auth.CreateInvitationToken([]byte(config.Envs.JWT_SECRET), invitationID, invitation.ExpiresAt)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.mailer.Send(notification.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to join the team",
+		Body:    fmt.Sprintf("You have been invited to create a staff account. Accept the invitation by sending this token with your name and password to POST /api/v1/users/invitations/accept before %s:\n\n%s", invitation.ExpiresAt.Format(time.RFC1123), token),
+	}); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, fmt.Errorf("failed to send invitation: %w", err))
+		return
+	}
+
+	created, err := h.store.GetInvitationByID(invitationID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "invitation.create", "invitation", invitationID, map[string]any{"email": invitation.Email, "role": invitation.Role})
+
+	utils.Log.WithFields(logrus.Fields{
+		"invitationID": invitationID,
+		"email":        invitation.Email,
+		"invitedBy":    c.GetInt(string(middleware.UserKey)),
+	}).Info("User invited")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, created)
+}
+
+// handleRevokeInvitation withdraws an invitation.
+//	@Summary		Revoke an invitation
+//	@Description	Withdraw an invitation that hasn't been accepted yet (requires roles:manage)
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Invitation ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"invalid invitation ID"
+//	@Failure		404	{object}	map[string]string	"invitation not found"
+//	@Failure		409	{object}	map[string]string	"invitation already accepted or revoked"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/invitations/{id} [delete]
+func (h *Handler) handleRevokeInvitation(c *gin.Context) {
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid invitation ID"))
+		return
+	}
+
+	if err := h.store.RevokeInvitation(invitationID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "invitation.revoke", "invitation", invitationID, nil)
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "invitation revoked"})
+}
+
+// handleAcceptInvitation creates a staff account from an invitation.
+//	@Summary		Accept an invitation
+//	@Description	Create the invited account with the email and role of the invitation, using the token that was sent by email. Each invitation can only be accepted once.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		types.AcceptInvitationPayload	true	"Acceptance payload"
+//	@Success		201		{object}	map[string]any					"user created"
+//	@Failure		400		{object}	map[string]string				"invalid payload or token"
+//	@Failure		409		{object}	map[string]string				"invitation already used or user already exists"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/users/invitations/accept [post]
+func (h *Handler) handleAcceptInvitation(c *gin.Context) {
+	var payload types.AcceptInvitationPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	invitationID, err := auth.ParseInvitationToken([]byte(config.Envs.JWT_SECRET), payload.Token)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	userID, err := h.store.AcceptInvitation(invitationID, types.User{
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+		Password:  hashedPassword,
+	})
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"invitationID": invitationID,
+		"userID":       userID,
+	}).Info("Invitation accepted")
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, map[string]any{"message": "user created", "success": true})
+}
+
+// writeStoreError maps user store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvitationNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrInvitationNotUsable), errors.Is(err, ErrUserExists):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,23 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// RegisterWellKnownRoutes registers the routes other services discover us through. They are
+// served from the root of the server rather than under the API base path.
+func (h *Handler) RegisterWellKnownRoutes(router gin.IRouter) {
+	router.GET("/.well-known/jwks.json", h.handleGetJWKS)
+}
+
+// handleGetJWKS publishes the public keys access tokens can be verified with (RFC 7517). Tokens
+// carry the ID of their key in the kid header. When tokens are signed with the shared
+// JWT_SECRET the key set is empty.
+func (h *Handler) handleGetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string][]auth.JWK{"keys": auth.JWKS()})
+}
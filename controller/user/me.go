@@ -0,0 +1,240 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// currentUser loads the authenticated user.
+func (h *Handler) currentUser(c *gin.Context) (*types.User, bool) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return nil, false
+	}
+
+	u, err := h.store.GetUserByID(userID.(int))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return nil, false
+	}
+
+	return u, true
+}
+
+// confirmPassword checks the current password of the user before a sensitive change. Wrong
+// passwords count against the login limits, so a stolen access token can't be used to guess it.
+func (h *Handler) confirmPassword(c *gin.Context, u *types.User, password string) bool {
+	limits := loginLimits(u.Email, c.ClientIP())
+	if !h.reserveLoginAttempt(c, limits) {
+		return false
+	}
+
+	if !auth.ComparePasswords(u.Password, []byte(password)) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid password"))
+		return false
+	}
+	h.releaseLoginAttempt(limits)
+
+	return true
+}
+
+// handleGetMe returns the profile of the authenticated user.
+//	@Summary		Get your profile
+//	@Description	Get the profile of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{object}	types.User			"profile"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Router			/users/me [get]
+func (h *Handler) handleGetMe(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, u)
+}
+
+// handleUpdateMe updates the profile of the authenticated user.
+//	@Summary		Update your profile
+//	@Description	Update the name of the authenticated user. The email and password have their own endpoints.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.UpdateProfilePayload	true	"Profile payload"
+//	@Success		200		{object}	types.User					"updated profile"
+//	@Failure		400		{object}	map[string]string			"invalid payload"
+//	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/me [put]
+func (h *Handler) handleUpdateMe(c *gin.Context) {
+	var payload types.UpdateProfilePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.UpdateProfile(u.ID, payload.FirstName, payload.LastName); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	u.FirstName, u.LastName = payload.FirstName, payload.LastName
+	utils.WriteJSON(c.Writer, http.StatusOK, u)
+}
+
+// handleChangeEmail changes the email of the authenticated user.
+//	@Summary		Change your email
+//	@Description	Change the email of the authenticated user, confirming the current password. The new address has to be verified again through the link sent to it, and the old address is told about the change.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.ChangeEmailPayload	true	"New email and current password"
+//	@Success		200		{object}	types.User					"updated profile"
+//	@Failure		400		{object}	map[string]string			"invalid payload or password"
+//	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		409		{object}	map[string]string			"email already in use"
+//	@Failure		429		{object}	map[string]string			"too many failed attempts"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/me/email [put]
+func (h *Handler) handleChangeEmail(c *gin.Context) {
+	var payload types.ChangeEmailPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if !h.confirmPassword(c, u, payload.Password) {
+		return
+	}
+	if payload.Email == u.Email {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("this is already your email"))
+		return
+	}
+
+	err := h.store.ChangeEmail(u.ID, payload.Email)
+	if errors.Is(err, ErrUserExists) {
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	oldEmail := u.Email
+	u.Email, u.EmailVerifiedAt = payload.Email, nil
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Info("Email changed")
+
+	// Tell the old address, in case someone else made the change
+	if err := h.mailer.Send(notification.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s. If you didn't do this, reset your password and contact us.", u.FirstName, u.Email),
+	}); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"userID": u.ID,
+			"error":  err,
+		}).Error("Failed to send email change notice")
+	}
+
+	// The change is made either way, so a failed email only means the user has to ask for another
+	if err := h.sendVerificationEmail(u); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"userID": u.ID,
+			"error":  err,
+		}).Error("Failed to send verification email")
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, u)
+}
+
+// handleChangePassword changes the password of the authenticated user.
+//	@Summary		Change your password
+//	@Description	Change the password of the authenticated user, confirming the current one. All other sessions are logged out.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.ChangePasswordPayload	true	"Current and new password"
+//	@Success		200		{object}	map[string]string			"message"
+//	@Failure		400		{object}	map[string]string			"invalid payload or password"
+//	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		429		{object}	map[string]string			"too many failed attempts"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/me/password [put]
+func (h *Handler) handleChangePassword(c *gin.Context) {
+	var payload types.ChangePasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if !h.confirmPassword(c, u, payload.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(payload.NewPassword)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.ChangePassword(u.ID, hashedPassword, c.GetString(string(middleware.SessionKey))); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Info("Password changed")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "password changed, other sessions have been logged out"})
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// sendPasswordReset emails the user a new password reset token through sender, explaining why
+// with reason. The token is only stored once the email went out, so an email the sender refuses,
+// for example because of its rate limit, doesn't replace the token of an earlier email.
+func (h *Handler) sendPasswordReset(sender notification.Sender, u *types.User, reason string) error {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(config.Envs.PASSWORD_RESET_TTL_SECONDS) * time.Second)
+
+	if err := sender.Send(notification.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n\nTo choose a new password, send this token with it to POST /api/v1/users/password/reset before %s:\n\n%s", u.FirstName, reason, expiresAt.Format(time.RFC1123), token),
+	}); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	return h.store.CreatePasswordResetToken(u.ID, hash, expiresAt)
+}
+
+// requestPasswordReset emails a password reset token to the account of email, if there is one.
+// Nobody waits for it, so errors are logged.
+func (h *Handler) requestPasswordReset(email string) {
+	u, err := h.store.GetUserByEmail(email)
+	if err != nil {
+		return
+	}
+
+	if err := h.sendPasswordReset(h.resetMailer, u, "Someone asked to reset the password of your account. If it wasn't you, you can ignore this email."); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"userID": u.ID,
+			"error":  err,
+		}).Error("Failed to send password reset email")
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Info("Password reset requested")
+}
+
+// handleForgotPassword emails a password reset token.
+//	@Summary		Request a password reset
+//	@Description	Email a single-use token that resets the password of the account. The response is the same whether or not an account exists for the email, and at most PASSWORD_RESET_REQUEST_LIMIT emails are sent per account within PASSWORD_RESET_REQUEST_WINDOW_SECONDS.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		types.ForgotPasswordPayload	true	"Email of the account"
+//	@Success		200		{object}	map[string]string			"message"
+//	@Failure		400		{object}	map[string]string			"invalid payload"
+//	@Router			/users/password/forgot [post]
+func (h *Handler) handleForgotPassword(c *gin.Context) {
+	var payload types.ForgotPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	// Don't tell whether the account exists. The account is looked up and emailed in the
+	// background, so the response is the same and takes as long either way.
+	go h.requestPasswordReset(payload.Email)
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "if an account exists for this email, instructions to reset its password have been sent"})
+}
+
+// handleResetPassword sets a new password with a reset token.
+//	@Summary		Reset a password
+//	@Description	Set a new password with a token from a password reset email. The token works once, and all sessions of the account are logged out.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		types.ResetPasswordPayload	true	"Reset payload"
+//	@Success		200		{object}	map[string]string			"message"
+//	@Failure		400		{object}	map[string]string			"invalid payload or token"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/password/reset [post]
+func (h *Handler) handleResetPassword(c *gin.Context) {
+	var payload types.ResetPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	userID, err := h.store.ResetPassword(auth.HashToken(payload.Token), hashedPassword)
+	if errors.Is(err, ErrInvalidResetToken) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": userID,
+	}).Info("Password reset")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "password has been reset, please log in again"})
+}
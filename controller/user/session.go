@@ -0,0 +1,155 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// issueTokens creates an access token and a refresh token for the user in the given session,
+// which was started by authenticating with methods.
+func (h *Handler) issueTokens(u *types.User, sessionID string, methods []string) (map[string]string, error) {
+	refreshToken, hash, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.store.CreateRefreshToken(types.RefreshToken{
+		UserID:      u.ID,
+		FamilyID:    sessionID,
+		TokenHash:   hash,
+		AuthMethods: methods,
+		ExpiresAt:   refreshTokenExpiry(),
+	}); err != nil {
+		return nil, err
+	}
+
+	token, err := auth.CreateJWT(u.ID, u.Role, sessionID, methods)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"token": token, "refreshToken": refreshToken}, nil
+}
+
+func refreshTokenExpiry() time.Time {
+	return time.Now().Add(time.Duration(config.Envs.REFRESH_TOKEN_TTL_SECONDS) * time.Second)
+}
+
+// handleRefresh exchanges a refresh token for new tokens.
+//	@Summary		Refresh tokens
+//	@Description	Exchange a refresh token for a new access token and a new refresh token. Each refresh token can only be used once; using one again revokes the whole session.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		types.RefreshTokenPayload	true	"Refresh payload"
+//	@Success		200		{object}	map[string]string			"token and refreshToken"
+//	@Failure		400		{object}	map[string]string			"invalid payload"
+//	@Failure		401		{object}	map[string]string			"invalid, expired or reused refresh token"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/refresh [post]
+func (h *Handler) handleRefresh(c *gin.Context) {
+	var payload types.RefreshTokenPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	refreshToken, hash, err := auth.NewToken()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	used, err := h.store.RotateRefreshToken(auth.HashToken(payload.RefreshToken), types.RefreshToken{
+		TokenHash: hash,
+		ExpiresAt: refreshTokenExpiry(),
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		utils.Log.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("Refresh token reused")
+	}
+	if err != nil {
+		writeSessionError(c, err)
+		return
+	}
+
+	// The role may have changed since the session started. How the user authenticated hasn't,
+	// so it comes from the session rather than their current two-factor settings.
+	u, err := h.store.GetUserByID(used.UserID)
+	if err != nil || u.DisabledAt != nil {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, ErrInvalidRefreshToken)
+		return
+	}
+
+	token, err := auth.CreateJWT(u.ID, u.Role, used.FamilyID, used.AuthMethods)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"token": token, "refreshToken": refreshToken})
+}
+
+// handleLogout ends the current session.
+//	@Summary		Logout
+//	@Description	Revoke the session of the access token, together with its refresh tokens. With all=true every session of the user is revoked.
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			all	query		bool				false	"Log out of every session"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/logout [post]
+func (h *Handler) handleLogout(c *gin.Context) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	var err error
+	if c.Query("all") == "true" {
+		err = h.store.RevokeUserSessions(userID.(int))
+	} else {
+		err = h.store.RevokeSession(c.GetString(string(middleware.SessionKey)))
+	}
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": userID,
+		"all":    c.Query("all") == "true",
+	}).Info("User logged out")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+// writeSessionError maps session errors to HTTP status codes.
+func writeSessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused):
+		utils.WriteError(c.Writer, http.StatusUnauthorized, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -1,12 +1,28 @@
 package user
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/youngprinnce/go-ecom/types"
 )
 
+var (
+	ErrUserExists          = errors.New("a user with this email already exists")
+	ErrInvitationNotFound  = errors.New("invitation not found")
+	ErrInvitationNotUsable = errors.New("invitation has already been accepted, revoked or has expired")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used, the session has been revoked")
+	ErrInvalidResetToken   = errors.New("invalid or expired password reset token")
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrUserDisabled        = errors.New("this account has been disabled")
+)
+
 type Store struct {
 	db *sql.DB
 }
@@ -25,18 +41,9 @@ func (s *Store) CreateUser(user types.User) error {
 }
 
 func (s *Store) GetUserByEmail(email string) (*types.User, error) {
-	row := s.db.QueryRow("SELECT * FROM users WHERE email = ?", email)
+	row := s.db.QueryRow("SELECT id, firstName, lastName, email, password, role, emailVerifiedAt, totpSecret, totpEnabledAt, disabledAt, createdAt FROM users WHERE email = ?", email)
 
-	u := new(types.User)
-	err := row.Scan(
-		&u.ID,
-		&u.FirstName,
-		&u.LastName,
-		&u.Email,
-		&u.Password,
-		&u.Role,
-		&u.CreatedAt,
-	)
+	u, err := scanUser(row)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("user not found")
 	} else if err != nil {
@@ -47,24 +54,832 @@ func (s *Store) GetUserByEmail(email string) (*types.User, error) {
 }
 
 func (s *Store) GetUserByID(id int) (*types.User, error) {
-	row := s.db.QueryRow("SELECT * FROM users WHERE id = ?", id)
+	row := s.db.QueryRow("SELECT id, firstName, lastName, email, password, role, emailVerifiedAt, totpSecret, totpEnabledAt, disabledAt, createdAt FROM users WHERE id = ?", id)
+
+	u, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// SearchUsers lists the users matching the query, newest first, one page at a time.
+func (s *Store) SearchUsers(query types.UserSearchQuery) (*types.UserPage, error) {
+	ctx := context.Background()
+
+	where := []string{"1 = 1"}
+	args := []any{}
+	if query.Query != "" {
+		pattern := "%" + escapeLike(query.Query) + "%"
+		where = append(where, "(CONCAT(firstName, ' ', lastName) LIKE ? OR email LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if query.Role != "" {
+		where = append(where, "role = ?")
+		args = append(args, query.Role)
+	}
+	switch query.Status {
+	case "active":
+		where = append(where, "disabledAt IS NULL")
+	case "disabled":
+		where = append(where, "disabledAt IS NOT NULL")
+	}
+	condition := strings.Join(where, " AND ")
+
+	page := &types.UserPage{Page: query.Page, PerPage: query.PerPage, Users: make([]types.User, 0)}
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+condition, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, firstName, lastName, email, password, role, emailVerifiedAt, totpSecret, totpEnabledAt, disabledAt, createdAt
+		FROM users
+		WHERE `+condition+`
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, query.PerPage, (query.Page-1)*query.PerPage)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		page.Users = append(page.Users, *u)
+	}
+
+	return page, rows.Err()
+}
+
+// SetUserDisabled disables or enables a user. Disabling also revokes all of the user's sessions.
+func (s *Store) SetUserDisabled(userID int, disabled bool) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE users SET disabledAt = NULL WHERE id = ?"
+	if disabled {
+		query = "UPDATE users SET disabledAt = COALESCE(disabledAt, CURRENT_TIMESTAMP) WHERE id = ?"
+	}
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if disabled {
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE userId = ? AND revokedAt IS NULL", userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserRole changes the role of a user and revokes the user's sessions, whose tokens carry
+// the old role.
+func (s *Store) SetUserRole(userID int, role string) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to change role: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, userID); err != nil {
+		return fmt.Errorf("failed to change role: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE userId = ? AND revokedAt IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to change role: %w", err)
+	}
+
+	return nil
+}
+
+// ForcePasswordReset clears the password of a user and revokes the user's sessions, so the
+// account can only be used again after a password reset. An empty hash matches no password.
+func (s *Store) ForcePasswordReset(userID int) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to force password reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = '' WHERE id = ?", userID); err != nil {
+		return fmt.Errorf("failed to force password reset: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE userId = ? AND revokedAt IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to force password reset: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateProfile updates the name of a user.
+func (s *Store) UpdateProfile(userID int, firstName, lastName string) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE users SET firstName = ?, lastName = ? WHERE id = ?", firstName, lastName, userID); err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return nil
+}
+
+// ChangeEmail changes the email of a user, who has to verify the new address.
+func (s *Store) ChangeEmail(userID int, email string) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE users SET email = ?, emailVerifiedAt = NULL WHERE id = ?", email, userID); err != nil {
+		if isDuplicateEntry(err) {
+			return ErrUserExists
+		}
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of a user and revokes all of the user's sessions
+// except keepSessionID, the one the password was changed from.
+func (s *Store) ChangePassword(userID int, hashedPassword string, keepSessionID string) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", hashedPassword, userID); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revokedAt = CURRENT_TIMESTAMP
+		WHERE userId = ? AND familyId <> ? AND revokedAt IS NULL
+	`, userID, keepSessionID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email of a user as verified, unless it already is.
+func (s *Store) VerifyEmail(userID int) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE users SET emailVerifiedAt = CURRENT_TIMESTAMP WHERE id = ? AND emailVerifiedAt IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return nil
+}
+
+// CreateInvitation stores a new invitation.
+func (s *Store) CreateInvitation(invitation types.UserInvitation) (int, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_invitations (email, role, invitedBy, expiresAt)
+		VALUES (?, ?, ?, ?)
+	`, invitation.Email, invitation.Role, invitation.InvitedBy, invitation.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	invitationID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return int(invitationID), nil
+}
+
+// GetInvitationByID retrieves an invitation by its ID.
+func (s *Store) GetInvitationByID(id int) (*types.UserInvitation, error) {
+	ctx := context.Background()
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, email, role, invitedBy, expiresAt, acceptedAt, revokedAt, createdAt
+		FROM user_invitations
+		WHERE id = ?
+	`, id)
+
+	invitation, err := scanInvitation(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// GetPendingInvitations retrieves the invitations that can still be accepted, newest first.
+func (s *Store) GetPendingInvitations() ([]types.UserInvitation, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, email, role, invitedBy, expiresAt, acceptedAt, revokedAt, createdAt
+		FROM user_invitations
+		WHERE acceptedAt IS NULL AND revokedAt IS NULL AND expiresAt > ?
+		ORDER BY createdAt DESC, id DESC
+	`, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := make([]types.UserInvitation, 0)
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, *invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation withdraws an invitation that hasn't been accepted yet.
+func (s *Store) RevokeInvitation(id int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE user_invitations
+		SET revokedAt = CURRENT_TIMESTAMP
+		WHERE id = ? AND acceptedAt IS NULL AND revokedAt IS NULL
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	if n == 0 {
+		if _, err := s.GetInvitationByID(id); err != nil {
+			return err
+		}
+		return ErrInvitationNotUsable
+	}
+
+	return nil
+}
+
+// AcceptInvitation creates the invited user with the email and role of the invitation and
+// marks the invitation as accepted, in one transaction so an invitation is only used once.
+// The email counts as verified, since the invitation was sent to it. It returns the ID of the
+// new user.
+func (s *Store) AcceptInvitation(invitationID int, user types.User) (int, error) {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitation, err := scanInvitation(tx.QueryRowContext(ctx, `
+		SELECT id, email, role, invitedBy, expiresAt, acceptedAt, revokedAt, createdAt
+		FROM user_invitations
+		WHERE id = ?
+		FOR UPDATE
+	`, invitationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvitationNotFound
+		}
+		return 0, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil || !invitation.ExpiresAt.After(time.Now()) {
+		return 0, ErrInvitationNotUsable
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO users (firstName, lastName, email, password, role, emailVerifiedAt)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, user.FirstName, user.LastName, invitation.Email, user.Password, invitation.Role)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return 0, ErrUserExists
+		}
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_invitations SET acceptedAt = CURRENT_TIMESTAMP WHERE id = ?", invitationID); err != nil {
+		return 0, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	return int(userID), nil
+}
+
+// CreateRefreshToken stores a refresh token, starting a session when its family is new.
+func (s *Store) CreateRefreshToken(token types.RefreshToken) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (userId, familyId, tokenHash, authMethods, expiresAt)
+		VALUES (?, ?, ?, ?, ?)
+	`, token.UserID, token.FamilyID, token.TokenHash, strings.Join(token.AuthMethods, ","), token.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken exchanges the refresh token with the given hash for next, which joins
+// the same family and user and keeps its authentication methods. A token can only be exchanged once: presenting it again means it
+// was stolen or leaked, so the whole family is revoked and ErrRefreshTokenReused returned.
+// It returns the exchanged token.
+func (s *Store) RotateRefreshToken(tokenHash string, next types.RefreshToken) (*types.RefreshToken, error) {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	defer tx.Rollback()
+
+	var token types.RefreshToken
+	var authMethods string
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, userId, familyId, tokenHash, authMethods, expiresAt, usedAt, revokedAt, createdAt
+		FROM refresh_tokens
+		WHERE tokenHash = ?
+		FOR UPDATE
+	`, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&authMethods,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	if revokedAt.Valid || !token.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+	token.AuthMethods = strings.Split(authMethods, ",")
+
+	if usedAt.Valid {
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE familyId = ? AND revokedAt IS NULL", token.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET usedAt = CURRENT_TIMESTAMP WHERE id = ?", token.ID); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (userId, familyId, tokenHash, authMethods, expiresAt)
+		VALUES (?, ?, ?, ?, ?)
+	`, token.UserID, token.FamilyID, next.TokenHash, authMethods, next.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// RevokeSession revokes every refresh token of a session, which also invalidates its access tokens.
+func (s *Store) RevokeSession(familyID string) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE familyId = ? AND revokedAt IS NULL", familyID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes all sessions of a user.
+func (s *Store) RevokeUserSessions(userID int) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE userId = ? AND revokedAt IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// IsSessionRevoked reports whether a session has been revoked. Sessions that were never
+// started count as revoked.
+func (s *Store) IsSessionRevoked(familyID string) (bool, error) {
+	ctx := context.Background()
+
+	var active bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE familyId = ? AND revokedAt IS NULL)
+	`, familyID).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return !active, nil
+}
+
+// IsUserDisabled reports whether a user has been disabled. Users that don't exist count as disabled.
+func (s *Store) IsUserDisabled(userID int) (bool, error) {
+	ctx := context.Background()
+
+	var enabled bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM users WHERE id = ? AND disabledAt IS NULL)
+	`, userID).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to check user: %w", err)
+	}
+
+	return !enabled, nil
+}
+
+// CreatePasswordResetToken stores a password reset token for a user. Tokens the user asked
+// for earlier stop working, so only the latest email can be used.
+func (s *Store) CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE userId = ? AND usedAt IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
 
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (userId, tokenHash, expiresAt)
+		VALUES (?, ?, ?)
+	`, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword uses the password reset token with the given hash to replace the password of
+// its user, and revokes all of the user's sessions. Each token works once. It returns the ID
+// of the user.
+func (s *Store) ResetPassword(tokenHash string, hashedPassword string) (int, error) {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, userId
+		FROM password_reset_tokens
+		WHERE tokenHash = ? AND usedAt IS NULL AND expiresAt > ?
+		FOR UPDATE
+	`, tokenHash, time.Now()).Scan(&tokenID, &userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE password_reset_tokens SET usedAt = CURRENT_TIMESTAMP WHERE id = ?", tokenID); err != nil {
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", hashedPassword, userID); err != nil {
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	// Whoever knew the old password may still be logged in
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE userId = ? AND revokedAt IS NULL", userID); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return userID, nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// SetTOTPSecret stores a new TOTP secret for a user who hasn't enabled two-factor
+// authentication yet. It only takes effect once EnableTOTP confirms the user's app has it.
+func (s *Store) SetTOTPSecret(userID int, secret string) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET totpSecret = ?, totpLastStep = NULL WHERE id = ? AND totpEnabledAt IS NULL", secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set TOTP secret: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set TOTP secret: %w", err)
+	}
+	if n == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication for a user with the secret set earlier,
+// recording step as the last used code and replacing the user's recovery codes. Sessions
+// started without a second factor are revoked, except keepSessionID, the one it was enabled from.
+func (s *Store) EnableTOTP(userID int, step int64, recoveryCodeHashes []string, keepSessionID string) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET totpEnabledAt = CURRENT_TIMESTAMP, totpLastStep = ?
+		WHERE id = ? AND totpSecret IS NOT NULL AND totpEnabledAt IS NULL
+	`, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	if n == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revokedAt = CURRENT_TIMESTAMP
+		WHERE userId = ? AND familyId <> ? AND revokedAt IS NULL
+	`, userID, keepSessionID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+// DisableTOTP turns off two-factor authentication for a user and deletes their secret and
+// recovery codes.
+func (s *Store) DisableTOTP(userID int) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totpSecret = NULL, totpEnabledAt = NULL, totpLastStep = NULL WHERE id = ?", userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE userId = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records that a user logged in with the TOTP code of step. It reports false
+// when that code, or a later one, has already been used, so a code works only once.
+func (s *Store) UseTOTPStep(userID int, step int64) (bool, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE users
+		SET totpLastStep = ?
+		WHERE id = ? AND (totpLastStep IS NULL OR totpLastStep < ?)
+	`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use TOTP code: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use TOTP code: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user.
+func (s *Store) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE userId = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (userId, codeHash) VALUES (?, ?)", userID, hash); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode uses up the recovery code of a user with the given hash. It reports false
+// when the user has no such unused code.
+func (s *Store) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE user_recovery_codes
+		SET usedAt = CURRENT_TIMESTAMP
+		WHERE userId = ? AND codeHash = ? AND usedAt IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func scanUser(row scanner) (*types.User, error) {
 	u := new(types.User)
-	err := row.Scan(
+	var emailVerifiedAt, totpEnabledAt, disabledAt sql.NullTime
+	var totpSecret sql.NullString
+	if err := row.Scan(
 		&u.ID,
 		&u.FirstName,
 		&u.LastName,
 		&u.Email,
 		&u.Password,
 		&u.Role,
+		&emailVerifiedAt,
+		&totpSecret,
+		&totpEnabledAt,
+		&disabledAt,
 		&u.CreatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
-	} else if err != nil {
+	); err != nil {
 		return nil, err
 	}
 
+	if emailVerifiedAt.Valid {
+		u.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	u.TOTPSecret = totpSecret.String
+	if totpEnabledAt.Valid {
+		u.TOTPEnabledAt = &totpEnabledAt.Time
+	}
+	if disabledAt.Valid {
+		u.DisabledAt = &disabledAt.Time
+	}
+
 	return u, nil
 }
 
+func scanInvitation(row scanner) (*types.UserInvitation, error) {
+	var invitation types.UserInvitation
+	var invitedBy sql.NullInt64
+	var acceptedAt, revokedAt sql.NullTime
+	if err := row.Scan(
+		&invitation.ID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitedBy,
+		&invitation.ExpiresAt,
+		&acceptedAt,
+		&revokedAt,
+		&invitation.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if invitedBy.Valid {
+		id := int(invitedBy.Int64)
+		invitation.InvitedBy = &id
+	}
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+	if revokedAt.Valid {
+		invitation.RevokedAt = &revokedAt.Time
+	}
+
+	return &invitation, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so they match literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique key violation.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
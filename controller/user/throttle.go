@@ -0,0 +1,160 @@
+package user
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/audit"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// loginLimit is how many failed logins a throttle key allows before each further failure
+// blocks it for a doubling delay.
+type loginLimit struct {
+	key         string
+	maxFailures int
+}
+
+// loginLimits lists the keys failed logins for email from ip count against. Accounts are
+// tracked whether or not they exist, so blocking doesn't tell which emails have accounts.
+func loginLimits(email, ip string) []loginLimit {
+	return []loginLimit{
+		{key: accountThrottleKey(email), maxFailures: int(config.Envs.LOGIN_MAX_FAILURES_PER_ACCOUNT)},
+		{key: "ip:" + ip, maxFailures: int(config.Envs.LOGIN_MAX_FAILURES_PER_IP)},
+	}
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginBackoff is how long a key is blocked after the given number of failures: nothing up
+// to the limit, then LOGIN_BACKOFF_SECONDS doubling with every failure, up to a lockout of
+// LOGIN_LOCKOUT_SECONDS.
+func loginBackoff(failures, maxFailures int) time.Duration {
+	if failures <= maxFailures {
+		return 0
+	}
+
+	lockout := time.Duration(config.Envs.LOGIN_LOCKOUT_SECONDS) * time.Second
+	exponent := float64(failures - maxFailures - 1)
+	backoff := float64(config.Envs.LOGIN_BACKOFF_SECONDS) * math.Pow(2, exponent) * float64(time.Second)
+	if backoff >= float64(lockout) {
+		return lockout
+	}
+	return time.Duration(backoff)
+}
+
+// reserveLoginAttempt counts a login attempt as failed against each of the limits before the
+// credentials are checked, and reports whether it may go ahead. While any of the limits is
+// blocked it refuses the attempt with 429 Too Many Requests. Attempts are counted and blocked
+// atomically, so concurrent attempts can't all get in before the first failure is recorded.
+// Attempts that turn out to be right are given back with releaseLoginAttempt.
+func (h *Handler) reserveLoginAttempt(c *gin.Context, limits []loginLimit) bool {
+	since := time.Now().Add(-time.Duration(config.Envs.LOGIN_FAILURE_WINDOW_SECONDS) * time.Second)
+
+	var wait time.Duration
+	var reserved []loginLimit
+	for _, limit := range limits {
+		maxFailures := limit.maxFailures
+		failures, blockedUntil, err := h.attempts.ReserveLoginAttempt(limit.key, since, func(failures int) time.Duration {
+			return loginBackoff(failures, maxFailures)
+		})
+		if err != nil {
+			h.releaseLoginAttempt(reserved)
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return false
+		}
+		if blockedUntil != nil {
+			if remaining := time.Until(*blockedUntil); remaining > wait {
+				wait = remaining
+			}
+			continue
+		}
+		reserved = append(reserved, limit)
+
+		if backoff := loginBackoff(failures, maxFailures); backoff > 0 {
+			utils.Log.WithFields(logrus.Fields{
+				"key":      limit.key,
+				"failures": failures,
+				"blocked":  backoff.String(),
+			}).Warn("Login blocked after failed attempts")
+		}
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	// The limits that weren't blocked counted an attempt that won't be made
+	h.releaseLoginAttempt(reserved)
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	utils.WriteError(c.Writer, http.StatusTooManyRequests, fmt.Errorf("too many failed login attempts, try again in %d seconds", seconds))
+	return false
+}
+
+// releaseLoginAttempt takes back an attempt counted by reserveLoginAttempt, once the credentials
+// turned out to be right or weren't checked.
+func (h *Handler) releaseLoginAttempt(limits []loginLimit) {
+	for _, limit := range limits {
+		if err := h.attempts.ReleaseLoginAttempt(limit.key); err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"key":   limit.key,
+				"error": err,
+			}).Error("Failed to release login attempt")
+		}
+	}
+}
+
+// clearLoginFailures forgets the failed logins of an account once it logs in. Failures by IP
+// are kept, so logging in to one account doesn't reset guessing at others.
+func (h *Handler) clearLoginFailures(email string) {
+	if err := h.attempts.ClearLoginFailures(accountThrottleKey(email)); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("Failed to clear login failures")
+	}
+}
+
+// handleUnlockUser lifts the login block of an account.
+//	@Summary		Unlock a user
+//	@Description	Forget the failed logins of an account, lifting its backoff or lockout (requires users:write). Blocks on IP addresses expire on their own.
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"User ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"invalid user ID"
+//	@Failure		404	{object}	map[string]string	"user not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/{id}/unlock [post]
+func (h *Handler) handleUnlockUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	u, err := h.store.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("user not found"))
+		return
+	}
+
+	if err := h.attempts.ClearLoginFailures(accountThrottleKey(u.Email)); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	audit.Record(c, h.audit, "user.unlock", "user", u.ID, nil)
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "user unlocked"})
+}
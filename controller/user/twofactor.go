@@ -0,0 +1,383 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/controller/auth/totp"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// recoveryCodeCount is how many recovery codes users get when they enable two-factor authentication
+const recoveryCodeCount = 10
+
+// ErrInvalidTwoFactorCode is returned when a code is neither a current TOTP code nor an unused recovery code
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor authentication code")
+
+// checkSecondFactor accepts a code from the user's authenticator app, or one of their recovery
+// codes. Either can only be used once.
+func (h *Handler) checkSecondFactor(u *types.User, code string) error {
+	if step, ok := totp.Validate(u.TOTPSecret, code, time.Now()); ok {
+		fresh, err := h.store.UseTOTPStep(u.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := h.store.UseRecoveryCode(u.ID, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Warn("Recovery code used")
+
+	return nil
+}
+
+// confirmSecondFactor checks a code of the authenticated user before changing their two-factor
+// settings. Wrong codes count against the same limits as wrong passwords, so codes can't be
+// guessed through these endpoints either. It writes the error response and returns false if the
+// code isn't accepted.
+func (h *Handler) confirmSecondFactor(c *gin.Context, u *types.User, code string) bool {
+	limits := loginLimits(u.Email, c.ClientIP())
+	if !h.reserveLoginAttempt(c, limits) {
+		return false
+	}
+
+	if err := h.checkSecondFactor(u, code); err != nil {
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			h.releaseLoginAttempt(limits)
+		}
+		writeTwoFactorError(c, err)
+		return false
+	}
+	h.releaseLoginAttempt(limits)
+
+	return true
+}
+
+// newRecoveryCodes generates recovery codes and the hashes they are stored under.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashToken(auth.NormalizeRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// handleSetupTwoFactor starts enrolling the user in two-factor authentication.
+//	@Summary		Set up two-factor authentication
+//	@Description	Generate a TOTP secret for the authenticated user. Add it to an authenticator app, usually by showing provisioningUri as a QR code, then confirm it at /users/2fa/verify. Setting up again replaces a secret that hasn't been confirmed.
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{object}	map[string]string	"secret and provisioningUri"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		409	{object}	map[string]string	"two-factor authentication already enabled"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/2fa/setup [post]
+func (h *Handler) handleSetupTwoFactor(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.SetTOTPSecret(u.ID, secret); err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{
+		"secret":          secret,
+		"provisioningUri": totp.ProvisioningURI(config.Envs.TOTP_ISSUER, u.Email, secret),
+	})
+}
+
+// handleVerifyTwoFactor enables two-factor authentication once the user shows a code from their app.
+//	@Summary		Enable two-factor authentication
+//	@Description	Confirm the secret from /users/2fa/setup with a code from the authenticator app, which enables two-factor authentication. The response holds recovery codes, each of which can replace a code once; they are not shown again.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.TwoFactorCodePayload	true	"Code from the authenticator app"
+//	@Success		200		{object}	map[string][]string			"recoveryCodes"
+//	@Failure		400		{object}	map[string]string			"invalid payload or code, or setup not started"
+//	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		409		{object}	map[string]string			"two-factor authentication already enabled"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/2fa/verify [post]
+func (h *Handler) handleVerifyTwoFactor(c *gin.Context) {
+	var payload types.TwoFactorCodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabledAt != nil {
+		utils.WriteError(c.Writer, http.StatusConflict, ErrTwoFactorEnabled)
+		return
+	}
+	if u.TOTPSecret == "" {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("set up two-factor authentication at /users/2fa/setup first"))
+		return
+	}
+
+	step, valid := totp.Validate(u.TOTPSecret, payload.Code, time.Now())
+	if !valid {
+		utils.WriteError(c.Writer, http.StatusBadRequest, ErrInvalidTwoFactorCode)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.EnableTOTP(u.ID, step, hashes, c.GetString(string(middleware.SessionKey))); err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Info("Two-factor authentication enabled")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string][]string{"recoveryCodes": codes})
+}
+
+// handleDisableTwoFactor turns off two-factor authentication.
+//	@Summary		Disable two-factor authentication
+//	@Description	Turn off two-factor authentication with the password and a code from the authenticator app or a recovery code. Staff, whose role has any permission, can't turn it off while REQUIRE_2FA_FOR_ADMINS is set.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.DisableTwoFactorPayload	true	"Password and code"
+//	@Success		200		{object}	map[string]string				"message"
+//	@Failure		400		{object}	map[string]string				"invalid payload, password or code"
+//	@Failure		401		{object}	map[string]string				"unauthorized"
+//	@Failure		403		{object}	map[string]string				"two-factor authentication is required"
+//	@Failure		429		{object}	map[string]string				"too many failed attempts"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/users/2fa/disable [post]
+func (h *Handler) handleDisableTwoFactor(c *gin.Context) {
+	var payload types.DisableTwoFactorPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabledAt == nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("two-factor authentication is not enabled"))
+		return
+	}
+	if config.Envs.REQUIRE_2FA_FOR_ADMINS {
+		// Staff are users whose role has been granted any permission
+		r, err := h.roles.GetRole(u.Role)
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return
+		}
+		if len(r.Permissions) > 0 {
+			utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("staff must use two-factor authentication"))
+			return
+		}
+	}
+
+	if !h.confirmPassword(c, u, payload.Password) {
+		return
+	}
+	if !h.confirmSecondFactor(c, u, payload.Code) {
+		return
+	}
+
+	if err := h.store.DisableTOTP(u.ID); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Info("Two-factor authentication disabled")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "two-factor authentication disabled"})
+}
+
+// handleRegenerateRecoveryCodes replaces the recovery codes of the user.
+//	@Summary		Regenerate recovery codes
+//	@Description	Replace all recovery codes with new ones, using a code from the authenticator app or a recovery code. The old codes stop working.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.TwoFactorCodePayload	true	"Code"
+//	@Success		200		{object}	map[string][]string			"recoveryCodes"
+//	@Failure		400		{object}	map[string]string			"invalid payload or code"
+//	@Failure		401		{object}	map[string]string			"unauthorized"
+//	@Failure		429		{object}	map[string]string			"too many failed attempts"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/2fa/recovery-codes [post]
+func (h *Handler) handleRegenerateRecoveryCodes(c *gin.Context) {
+	var payload types.TwoFactorCodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabledAt == nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("two-factor authentication is not enabled"))
+		return
+	}
+	if !h.confirmSecondFactor(c, u, payload.Code) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.ReplaceRecoveryCodes(u.ID, hashes); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string][]string{"recoveryCodes": codes})
+}
+
+// handleLoginTwoFactor completes a login with a second factor.
+//	@Summary		Complete a login with two-factor authentication
+//	@Description	Exchange the mfaToken from /users/login and a code from the authenticator app, or a recovery code, for an access token and a refresh token.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		types.LoginTwoFactorPayload	true	"MFA token and code"
+//	@Success		200		{object}	map[string]string			"token and refreshToken"
+//	@Failure		400		{object}	map[string]string			"invalid payload"
+//	@Failure		401		{object}	map[string]string			"invalid or expired MFA token, or invalid code"
+//	@Failure		403		{object}	map[string]string			"account disabled"
+//	@Failure		429		{object}	map[string]string			"too many failed attempts"
+//	@Failure		500		{object}	map[string]string			"internal server error"
+//	@Router			/users/login/2fa [post]
+func (h *Handler) handleLoginTwoFactor(c *gin.Context) {
+	var payload types.LoginTwoFactorPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	userID, err := auth.ParseMFAToken([]byte(config.Envs.JWT_SECRET), payload.MFAToken)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, err)
+		return
+	}
+
+	u, err := h.store.GetUserByID(userID)
+	if err != nil || u.TOTPEnabledAt == nil {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid or expired MFA token, log in again"))
+		return
+	}
+	if u.DisabledAt != nil {
+		utils.WriteError(c.Writer, http.StatusForbidden, ErrUserDisabled)
+		return
+	}
+
+	// Wrong codes count against the same limits as wrong passwords
+	limits := loginLimits(u.Email, c.ClientIP())
+	if !h.reserveLoginAttempt(c, limits) {
+		return
+	}
+
+	if err := h.checkSecondFactor(u, payload.Code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			utils.WriteError(c.Writer, http.StatusUnauthorized, err)
+			return
+		}
+		h.releaseLoginAttempt(limits)
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	h.releaseLoginAttempt(limits)
+
+	h.startSession(c, u, []string{auth.AuthMethodPassword, auth.AuthMethodOTP})
+}
+
+// writeTwoFactorError maps two-factor authentication errors to HTTP status codes.
+func writeTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTwoFactorCode):
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+	case errors.Is(err, ErrTwoFactorEnabled):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
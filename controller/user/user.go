@@ -3,41 +3,107 @@ package user
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 	"github.com/youngprinnce/go-ecom/config"
 	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/notification"
 	"github.com/youngprinnce/go-ecom/types"
 	"github.com/youngprinnce/go-ecom/utils"
 )
 
 type Handler struct {
-	store types.UserStore
+	store    types.UserStore
+	roles    types.RoleStore
+	attempts types.LoginAttemptStore
+	audit    types.AuditStore
+	mailer   notification.Sender
+	// verificationMailer sends verification emails, which users can ask for again, so it
+	// should be rate limited
+	verificationMailer notification.Sender
+	// resetMailer sends the password reset emails anyone can ask for, so it should be rate limited
+	resetMailer notification.Sender
+	// verifyPath is the path verification links point to, set when the routes are registered
+	verifyPath string
 }
 
-func NewHandler(store types.UserStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.UserStore, roleStore types.RoleStore, attempts types.LoginAttemptStore, auditStore types.AuditStore, mailer notification.Sender, verificationMailer notification.Sender, resetMailer notification.Sender) *Handler {
+	return &Handler{
+		store:              store,
+		roles:              roleStore,
+		attempts:           attempts,
+		audit:              auditStore,
+		mailer:             mailer,
+		verificationMailer: verificationMailer,
+		resetMailer:        resetMailer,
+	}
 }
 
 func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 	userRouter := router.Group("/users")
 	userRouter.POST("/login", h.handleLogin)
+	userRouter.POST("/login/2fa", h.handleLoginTwoFactor)
 	userRouter.POST("/register", h.handleRegister)
+	userRouter.POST("/refresh", h.handleRefresh)
+	userRouter.POST("/logout", middleware.JWTAuth(), h.handleLogout)
+	userRouter.POST("/password/forgot", h.handleForgotPassword)
+	userRouter.POST("/password/reset", h.handleResetPassword)
+	userRouter.POST("/invitations/accept", h.handleAcceptInvitation)
+	userRouter.GET("/verify-email", h.handleVerifyEmail)
+	userRouter.POST("/verify-email/resend", middleware.JWTAuth(), h.handleResendVerification)
+	h.verifyPath = router.BasePath() + "/users/verify-email"
+
+	twoFactorRouter := userRouter.Group("/2fa")
+	twoFactorRouter.Use(middleware.JWTAuth())
+	twoFactorRouter.POST("/setup", h.handleSetupTwoFactor)
+	twoFactorRouter.POST("/verify", h.handleVerifyTwoFactor)
+	twoFactorRouter.POST("/disable", h.handleDisableTwoFactor)
+	twoFactorRouter.POST("/recovery-codes", h.handleRegenerateRecoveryCodes)
+
+	// Staff accounts are only created through invitations, sent by those who manage roles
+	invitationRouter := userRouter.Group("/invitations")
+	invitationRouter.Use(middleware.JWTAuth(), middleware.RequirePermission(types.PermissionRolesManage))
+	invitationRouter.GET("", h.handleGetInvitations)
+	invitationRouter.POST("", h.handleCreateInvitation)
+	invitationRouter.DELETE("/:id", h.handleRevokeInvitation)
+
+	meRouter := userRouter.Group("/me")
+	meRouter.Use(middleware.JWTAuth())
+	meRouter.GET("", h.handleGetMe)
+	meRouter.PUT("", h.handleUpdateMe)
+	meRouter.PUT("/email", h.handleChangeEmail)
+	meRouter.PUT("/password", h.handleChangePassword)
+
+	adminRouter := userRouter.Group("")
+	adminRouter.Use(middleware.JWTAuth())
+	read := middleware.RequirePermission(types.PermissionUsersRead)
+	write := middleware.RequirePermission(types.PermissionUsersWrite)
+	adminRouter.GET("", read, h.handleSearchUsers)
+	adminRouter.GET("/:id", read, h.handleGetUser)
+	adminRouter.POST("/:id/disable", write, h.handleDisableUser)
+	adminRouter.POST("/:id/enable", write, h.handleEnableUser)
+	adminRouter.PUT("/:id/role", middleware.RequirePermission(types.PermissionRolesManage), h.handleUpdateUserRole)
+	adminRouter.POST("/:id/password-reset", write, h.handleForcePasswordReset)
+	adminRouter.POST("/:id/unlock", write, h.handleUnlockUser)
 }
 
 // handleLogin handles user login.
 //
 //	@Summary		Login
-//	@Description	Login with email and password
+//	@Description	Login with email and password. Users with two-factor authentication get an mfaToken instead of tokens, which completes the login at /users/login/2fa.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		types.LoginUserPayload	true	"Login payload"
-//	@Success		200		{object}	map[string]string		"token"
+//	@Success		200		{object}	map[string]any			"token and refreshToken, or mfaRequired and mfaToken"
 //	@Failure		400		{object}	map[string]any			"invalid payload"
 //	@Failure		401		{object}	map[string]any			"invalid email or password"
+//	@Failure		403		{object}	map[string]any			"account disabled"
+//	@Failure		429		{object}	map[string]any			"too many failed attempts"
 //	@Failure		500		{object}	map[string]any			"internal server error"
 //	@Router			/users/login [post]
 func (h *Handler) handleLogin(c *gin.Context) {
@@ -57,8 +123,16 @@ func (h *Handler) handleLogin(c *gin.Context) {
 		return
 	}
 
+	// Back off after repeated failures for the account or from the IP address
+	limits := loginLimits(user.Email, c.ClientIP())
+	if !h.reserveLoginAttempt(c, limits) {
+		return
+	}
+
 	u, err := h.store.GetUserByEmail(user.Email)
 	if err != nil {
+		// Take as long as a wrong password so unknown emails can't be told apart
+		auth.CompareDummyPassword([]byte(user.Password))
 		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("not found, invalid email or password"))
 		return
 	}
@@ -67,24 +141,56 @@ func (h *Handler) handleLogin(c *gin.Context) {
 		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("not found, invalid email or password"))
 		return
 	}
+	h.releaseLoginAttempt(limits)
+
+	if u.DisabledAt != nil {
+		utils.WriteError(c.Writer, http.StatusForbidden, ErrUserDisabled)
+		return
+	}
+
+	// With two-factor authentication the login is completed at /users/login/2fa
+	if u.TOTPEnabledAt != nil {
+		expiresAt := time.Now().Add(time.Duration(config.Envs.MFA_TOKEN_TTL_SECONDS) * time.Second)
+		mfaToken, err := auth.CreateMFAToken([]byte(config.Envs.JWT_SECRET), u.ID, expiresAt)
+		if err != nil {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return
+		}
 
-	secret := []byte(config.Envs.JWT_SECRET)
-	token, err := auth.CreateJWT(secret, u.ID, u.Role)
+		utils.WriteJSON(c.Writer, http.StatusOK, map[string]any{"mfaRequired": true, "mfaToken": mfaToken})
+		return
+	}
+
+	h.startSession(c, u, []string{auth.AuthMethodPassword})
+}
+
+// startSession logs the user in, starting a new session. methods lists how they authenticated.
+func (h *Handler) startSession(c *gin.Context, u *types.User, methods []string) {
+	// Every login starts a new session
+	sessionID, err := auth.NewSessionID()
 	if err != nil {
 		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
 		return
 	}
 
+	tokens, err := h.issueTokens(u, sessionID, methods)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.clearLoginFailures(u.Email)
+
 	utils.Log.WithFields(logrus.Fields{
-		"email": user.Email,
+		"email": u.Email,
 	}).Info("User logged in successfully")
-	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"token": token})
+	utils.WriteJSON(c.Writer, http.StatusOK, tokens)
 }
 
 // handleRegister handles user registration.
 //
 //	@Summary		Register
-//	@Description	Register a new user
+//	@Description	Register a new customer account and email a link that verifies its email address. Staff accounts are created through invitations.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -110,11 +216,6 @@ func (h *Handler) handleRegister(c *gin.Context) {
 		return
 	}
 
-	// Validate role (default to "user" if not provided)
-	if user.Role == "" {
-		user.Role = "user"
-	}
-
 	// Check if user exists
 	_, err := h.store.GetUserByEmail(user.Email)
 	if err == nil {
@@ -135,7 +236,7 @@ func (h *Handler) handleRegister(c *gin.Context) {
 		LastName:  user.LastName,
 		Email:     user.Email,
 		Password:  hashedPassword,
-		Role:      user.Role,
+		Role:      types.RoleUser, // public registration only ever creates customers
 	})
 	if err != nil {
 		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
@@ -146,5 +247,18 @@ func (h *Handler) handleRegister(c *gin.Context) {
 	utils.Log.WithFields(logrus.Fields{
 		"email": user.Email,
 	}).Info("New user registered")
+
+	// The account exists either way, so a failed email only means the user has to ask for another
+	created, err := h.store.GetUserByEmail(user.Email)
+	if err == nil {
+		err = h.sendVerificationEmail(created)
+	}
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"email": user.Email,
+			"error": err,
+		}).Error("Failed to send verification email")
+	}
+
 	utils.WriteJSON(c.Writer, http.StatusCreated, map[string]any{"message": "user created", "success": true})
 }
@@ -0,0 +1,124 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// sendVerificationEmail emails the user a signed link that verifies their email address.
+func (h *Handler) sendVerificationEmail(u *types.User) error {
+	expiresAt := time.Now().Add(time.Duration(config.Envs.EMAIL_VERIFICATION_TTL_SECONDS) * time.Second)
+	expires := expiresAt.Unix()
+
+	query := url.Values{}
+	query.Set("userId", strconv.Itoa(u.ID))
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", auth.SignEmailVerification([]byte(config.Envs.JWT_SECRET), u.ID, u.Email, expires))
+	link := config.Envs.APP_URL + h.verifyPath + "?" + query.Encode()
+
+	return h.verificationMailer.Send(notification.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body:    fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening this link before %s:\n\n%s", u.FirstName, expiresAt.Format(time.RFC1123), link),
+	})
+}
+
+// handleVerifyEmail verifies an email address from the link in a verification email.
+//	@Summary		Verify an email address
+//	@Description	Verify the email address of an account with the signed link from a verification email. The link stops working when it expires or the email of the account changes.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId		query		int					true	"User ID"
+//	@Param			expires		query		int					true	"Expiry of the link as a Unix timestamp"
+//	@Param			signature	query		string				true	"Link signature"
+//	@Success		200			{object}	map[string]string	"message"
+//	@Failure		400			{object}	map[string]string	"invalid or expired link"
+//	@Failure		500			{object}	map[string]string	"internal server error"
+//	@Router			/users/verify-email [get]
+func (h *Handler) handleVerifyEmail(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Query("userId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid verification link"))
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid verification link"))
+		return
+	}
+
+	u, err := h.store.GetUserByID(userID)
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid verification link"))
+		return
+	}
+
+	// Check the signature before the expiry so an altered expiry is reported as invalid
+	if !auth.CheckEmailVerification([]byte(config.Envs.JWT_SECRET), u.ID, u.Email, expires, c.Query("signature")) {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid verification link"))
+		return
+	}
+	if time.Now().Unix() > expires {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("verification link has expired, ask for a new one"))
+		return
+	}
+
+	if err := h.store.VerifyEmail(u.ID); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"userID": u.ID,
+	}).Info("Email verified")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "email verified"})
+}
+
+// handleResendVerification sends a new verification email.
+//	@Summary		Resend the verification email
+//	@Description	Send a new verification link to the email address of the authenticated user. Only a few emails are sent per hour.
+//	@Tags			users
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		409	{object}	map[string]string	"email already verified"
+//	@Failure		429	{object}	map[string]string	"too many verification emails"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/users/verify-email/resend [post]
+func (h *Handler) handleResendVerification(c *gin.Context) {
+	u, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if u.EmailVerifiedAt != nil {
+		utils.WriteError(c.Writer, http.StatusConflict, fmt.Errorf("email is already verified"))
+		return
+	}
+
+	err := h.sendVerificationEmail(u)
+	if errors.Is(err, notification.ErrRateLimited) {
+		utils.WriteError(c.Writer, http.StatusTooManyRequests, fmt.Errorf("too many verification emails sent, try again later"))
+		return
+	}
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, fmt.Errorf("failed to send verification email: %w", err))
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "verification email sent"})
+}
@@ -0,0 +1,55 @@
+package wishlist
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/notification"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// Notifier tells customers when a product on one of their wishlists is back in stock.
+type Notifier struct {
+	store  types.WishlistStore
+	sender notification.Sender
+}
+
+func NewNotifier(store types.WishlistStore, sender notification.Sender) *Notifier {
+	return &Notifier{
+		store:  store,
+		sender: sender,
+	}
+}
+
+// NotifyRestocked sends every user wishing for the product a back-in-stock notification.
+// Failures are logged, a notification that can't be sent is not retried.
+func (n *Notifier) NotifyRestocked(product types.Product) {
+	if !product.IsAvailable() {
+		return
+	}
+
+	users, err := n.store.GetUsersWishingFor(product.ID)
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"productID": product.ID,
+			"error":     err,
+		}).Error("Failed to look up wishlists for restocked product")
+		return
+	}
+
+	for _, user := range users {
+		err := n.sender.Send(notification.Message{
+			To:      user.Email,
+			Subject: fmt.Sprintf("%s is back in stock", product.Name),
+			Body:    fmt.Sprintf("Hi %s, %s from your wishlist is back in stock.", user.FirstName, product.Name),
+		})
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"productID": product.ID,
+				"userID":    user.ID,
+				"error":     err,
+			}).Warn("Failed to send back-in-stock notification")
+		}
+	}
+}
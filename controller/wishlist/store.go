@@ -0,0 +1,277 @@
+package wishlist
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngprinnce/go-ecom/types"
+)
+
+var (
+	ErrWishlistNotFound = errors.New("wishlist not found")
+	ErrWishlistExists   = errors.New("you already have a wishlist with this name")
+	ErrItemNotFound     = errors.New("product is not in the wishlist")
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetWishlistsByUserID retrieves all wishlists of a user with their items, oldest list first.
+func (s *Store) GetWishlistsByUserID(userID int) ([]types.Wishlist, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, userId, name, shareToken, createdAt
+		FROM wishlists
+		WHERE userId = ?
+		ORDER BY createdAt, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wishlists: %w", err)
+	}
+	defer rows.Close()
+
+	wishlists := make([]types.Wishlist, 0)
+	for rows.Next() {
+		wishlist, err := scanWishlist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan wishlist: %w", err)
+		}
+		wishlists = append(wishlists, *wishlist)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range wishlists {
+		if wishlists[i].Items, err = s.getItems(ctx, wishlists[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return wishlists, nil
+}
+
+// GetWishlistByID retrieves a wishlist with its items.
+func (s *Store) GetWishlistByID(id int) (*types.Wishlist, error) {
+	return s.getWishlist("id = ?", id)
+}
+
+// GetWishlistByShareToken retrieves a shared wishlist with its items.
+func (s *Store) GetWishlistByShareToken(token string) (*types.Wishlist, error) {
+	return s.getWishlist("shareToken = ?", token)
+}
+
+func (s *Store) getWishlist(where string, arg interface{}) (*types.Wishlist, error) {
+	ctx := context.Background()
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, userId, name, shareToken, createdAt
+		FROM wishlists
+		WHERE `+where, arg)
+
+	wishlist, err := scanWishlist(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWishlistNotFound
+		}
+		return nil, fmt.Errorf("failed to get wishlist: %w", err)
+	}
+
+	if wishlist.Items, err = s.getItems(ctx, wishlist.ID); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+// getItems retrieves the items of a wishlist, most recently added first.
+func (s *Store) getItems(ctx context.Context, wishlistID int) ([]types.WishlistItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT productId, createdAt
+		FROM wishlist_items
+		WHERE wishlistId = ?
+		ORDER BY createdAt DESC, productId
+	`, wishlistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wishlist items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]types.WishlistItem, 0)
+	for rows.Next() {
+		var item types.WishlistItem
+		if err := rows.Scan(&item.ProductID, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan wishlist item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// CreateWishlist stores a new, empty wishlist.
+func (s *Store) CreateWishlist(wishlist types.Wishlist) (int, error) {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO wishlists (userId, name)
+		VALUES (?, ?)
+	`, wishlist.UserID, wishlist.Name)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return 0, ErrWishlistExists
+		}
+		return 0, fmt.Errorf("failed to create wishlist: %w", err)
+	}
+
+	wishlistID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	return int(wishlistID), nil
+}
+
+// RenameWishlist changes the name of a wishlist.
+func (s *Store) RenameWishlist(wishlistID int, name string) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE wishlists SET name = ? WHERE id = ?", name, wishlistID); err != nil {
+		if isDuplicateEntry(err) {
+			return ErrWishlistExists
+		}
+		return fmt.Errorf("failed to rename wishlist: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWishlist deletes a wishlist and its items.
+func (s *Store) DeleteWishlist(wishlistID int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM wishlists WHERE id = ?", wishlistID)
+	if err != nil {
+		return fmt.Errorf("failed to delete wishlist: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete wishlist: %w", err)
+	}
+	if n == 0 {
+		return ErrWishlistNotFound
+	}
+
+	return nil
+}
+
+// SetWishlistShareToken shares a wishlist under token, or stops sharing it when token is empty.
+func (s *Store) SetWishlistShareToken(wishlistID int, token string) error {
+	ctx := context.Background()
+
+	var shareToken sql.NullString
+	if token != "" {
+		shareToken = sql.NullString{String: token, Valid: true}
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE wishlists SET shareToken = ? WHERE id = ?", shareToken, wishlistID); err != nil {
+		return fmt.Errorf("failed to update wishlist sharing: %w", err)
+	}
+
+	return nil
+}
+
+// AddWishlistItem adds a product to a wishlist. Adding a product that is already there does nothing.
+func (s *Store) AddWishlistItem(wishlistID, productID int) error {
+	ctx := context.Background()
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT IGNORE INTO wishlist_items (wishlistId, productId)
+		VALUES (?, ?)
+	`, wishlistID, productID); err != nil {
+		return fmt.Errorf("failed to add wishlist item: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveWishlistItem removes a product from a wishlist.
+func (s *Store) RemoveWishlistItem(wishlistID, productID int) error {
+	ctx := context.Background()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM wishlist_items WHERE wishlistId = ? AND productId = ?", wishlistID, productID)
+	if err != nil {
+		return fmt.Errorf("failed to remove wishlist item: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to remove wishlist item: %w", err)
+	}
+	if n == 0 {
+		return ErrItemNotFound
+	}
+
+	return nil
+}
+
+// GetUsersWishingFor retrieves the users with a product in any of their wishlists.
+func (s *Store) GetUsersWishingFor(productID int) ([]types.User, error) {
+	ctx := context.Background()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT u.id, u.firstName, u.lastName, u.email
+		FROM wishlist_items wi
+		JOIN wishlists w ON w.id = wi.wishlistId
+		JOIN users u ON u.id = w.userId
+		WHERE wi.productId = ?
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wishlist users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]types.User, 0)
+	for rows.Next() {
+		var u types.User
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan wishlist user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWishlist scans a wishlist row, without its items.
+func scanWishlist(row scanner) (*types.Wishlist, error) {
+	var wishlist types.Wishlist
+	var shareToken sql.NullString
+	if err := row.Scan(&wishlist.ID, &wishlist.UserID, &wishlist.Name, &shareToken, &wishlist.CreatedAt); err != nil {
+		return nil, err
+	}
+	wishlist.ShareToken = shareToken.String
+
+	return &wishlist, nil
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique key violation.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
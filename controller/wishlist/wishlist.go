@@ -0,0 +1,517 @@
+package wishlist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/middleware"
+	"github.com/youngprinnce/go-ecom/types"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+type Handler struct {
+	store        types.WishlistStore
+	productStore types.ProductStore
+}
+
+func NewHandler(store types.WishlistStore, productStore types.ProductStore) *Handler {
+	return &Handler{
+		store:        store,
+		productStore: productStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	wishlistRouter := router.Group("/wishlists")
+
+	// Shared wishlists can be viewed by anyone with the link
+	wishlistRouter.GET("/shared/:token", h.handleGetSharedWishlist)
+
+	// Customers manage their own wishlists
+	wishlistRouter.Use(middleware.JWTAuth())
+	wishlistRouter.GET("", h.handleGetWishlists)
+	wishlistRouter.POST("", h.handleCreateWishlist)
+	wishlistRouter.GET("/:id", h.handleGetWishlist)
+	wishlistRouter.PUT("/:id", h.handleRenameWishlist)
+	wishlistRouter.DELETE("/:id", h.handleDeleteWishlist)
+	wishlistRouter.POST("/:id/share", h.handleShareWishlist)
+	wishlistRouter.DELETE("/:id/share", h.handleUnshareWishlist)
+	wishlistRouter.POST("/:id/items", h.handleAddWishlistItem)
+	wishlistRouter.DELETE("/:id/items/:productId", h.handleRemoveWishlistItem)
+	wishlistRouter.POST("/:id/items/:productId/move-to-cart", h.handleMoveToCart)
+}
+
+// handleGetWishlists lists the wishlists of the authenticated user.
+//	@Summary		Get wishlists
+//	@Description	List the authenticated user's wishlists with their items. Items whose product can no longer be bought have no product.
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Success		200	{array}		types.Wishlist		"wishlists"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/wishlists [get]
+func (h *Handler) handleGetWishlists(c *gin.Context) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	wishlists, err := h.store.GetWishlistsByUserID(userID.(int))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range wishlists {
+		if err := h.loadProducts(wishlists[i].Items); err != nil {
+			utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, wishlists)
+}
+
+// handleCreateWishlist creates a named wishlist.
+//	@Summary		Create a wishlist
+//	@Description	Create an empty wishlist. Names are unique per user.
+//	@Tags			wishlists
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			payload	body		types.WishlistPayload	true	"Wishlist payload"
+//	@Success		201		{object}	types.Wishlist			"created wishlist"
+//	@Failure		400		{object}	map[string]string		"invalid payload"
+//	@Failure		401		{object}	map[string]string		"unauthorized"
+//	@Failure		409		{object}	map[string]string		"wishlist name already used"
+//	@Failure		500		{object}	map[string]string		"internal server error"
+//	@Router			/wishlists [post]
+func (h *Handler) handleCreateWishlist(c *gin.Context) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	var payload types.WishlistPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	wishlistID, err := h.store.CreateWishlist(types.Wishlist{
+		UserID: userID.(int),
+		Name:   payload.Name,
+	})
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	wishlist, err := h.store.GetWishlistByID(wishlistID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusCreated, wishlist)
+}
+
+// handleGetWishlist retrieves one of the authenticated user's wishlists.
+//	@Summary		Get a wishlist
+//	@Description	Get a wishlist of the authenticated user with its items
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Wishlist ID"
+//	@Success		200	{object}	types.Wishlist		"wishlist"
+//	@Failure		400	{object}	map[string]string	"invalid wishlist ID"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		404	{object}	map[string]string	"wishlist not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/wishlists/{id} [get]
+func (h *Handler) handleGetWishlist(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	if err := h.loadProducts(wishlist.Items); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, wishlist)
+}
+
+// handleRenameWishlist renames one of the authenticated user's wishlists.
+//	@Summary		Rename a wishlist
+//	@Description	Change the name of a wishlist of the authenticated user
+//	@Tags			wishlists
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int						true	"Wishlist ID"
+//	@Param			payload	body		types.WishlistPayload	true	"Wishlist payload"
+//	@Success		200		{object}	map[string]string		"message"
+//	@Failure		400		{object}	map[string]string		"invalid wishlist ID or payload"
+//	@Failure		401		{object}	map[string]string		"unauthorized"
+//	@Failure		404		{object}	map[string]string		"wishlist not found"
+//	@Failure		409		{object}	map[string]string		"wishlist name already used"
+//	@Failure		500		{object}	map[string]string		"internal server error"
+//	@Router			/wishlists/{id} [put]
+func (h *Handler) handleRenameWishlist(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	var payload types.WishlistPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	if err := h.store.RenameWishlist(wishlist.ID, payload.Name); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "wishlist renamed"})
+}
+
+// handleDeleteWishlist deletes one of the authenticated user's wishlists.
+//	@Summary		Delete a wishlist
+//	@Description	Delete a wishlist of the authenticated user and all its items
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Wishlist ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"invalid wishlist ID"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		404	{object}	map[string]string	"wishlist not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/wishlists/{id} [delete]
+func (h *Handler) handleDeleteWishlist(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteWishlist(wishlist.ID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "wishlist deleted"})
+}
+
+// handleShareWishlist shares a wishlist through a read-only link.
+//	@Summary		Share a wishlist
+//	@Description	Create a share token for a wishlist. Anyone with the token can view the wishlist at /wishlists/shared/{token}. Sharing again replaces the token, so older links stop working.
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Wishlist ID"
+//	@Success		200	{object}	map[string]string	"shareToken"
+//	@Failure		400	{object}	map[string]string	"invalid wishlist ID"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		404	{object}	map[string]string	"wishlist not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/wishlists/{id}/share [post]
+func (h *Handler) handleShareWishlist(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+	shareToken := hex.EncodeToString(token)
+
+	if err := h.store.SetWishlistShareToken(wishlist.ID, shareToken); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"shareToken": shareToken})
+}
+
+// handleUnshareWishlist stops sharing a wishlist.
+//	@Summary		Stop sharing a wishlist
+//	@Description	Remove the share token of a wishlist so its link stops working
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id	path		int					true	"Wishlist ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"invalid wishlist ID"
+//	@Failure		401	{object}	map[string]string	"unauthorized"
+//	@Failure		404	{object}	map[string]string	"wishlist not found"
+//	@Failure		500	{object}	map[string]string	"internal server error"
+//	@Router			/wishlists/{id}/share [delete]
+func (h *Handler) handleUnshareWishlist(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.SetWishlistShareToken(wishlist.ID, ""); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "wishlist no longer shared"})
+}
+
+// handleGetSharedWishlist shows a shared wishlist.
+//	@Summary		View a shared wishlist
+//	@Description	View a wishlist through its share token. The view is read-only and leaves out the owner.
+//	@Tags			wishlists
+//	@Produce		json
+//	@Param			token	path		string					true	"Share token"
+//	@Success		200		{object}	types.SharedWishlist	"shared wishlist"
+//	@Failure		404		{object}	map[string]string		"wishlist not found"
+//	@Failure		500		{object}	map[string]string		"internal server error"
+//	@Router			/wishlists/shared/{token} [get]
+func (h *Handler) handleGetSharedWishlist(c *gin.Context) {
+	wishlist, err := h.store.GetWishlistByShareToken(c.Param("token"))
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	if err := h.loadProducts(wishlist.Items); err != nil {
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, types.SharedWishlist{
+		Name:      wishlist.Name,
+		Items:     wishlist.Items,
+		CreatedAt: wishlist.CreatedAt,
+	})
+}
+
+// handleAddWishlistItem adds a product to a wishlist.
+//	@Summary		Add a product to a wishlist
+//	@Description	Save a product for later. Products that are out of stock can be added too, their owners are told when they are back in stock.
+//	@Tags			wishlists
+//	@Accept			json
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id		path		int								true	"Wishlist ID"
+//	@Param			payload	body		types.AddWishlistItemPayload	true	"Item payload"
+//	@Success		200		{object}	map[string]string				"message"
+//	@Failure		400		{object}	map[string]string				"invalid wishlist ID or payload"
+//	@Failure		401		{object}	map[string]string				"unauthorized"
+//	@Failure		404		{object}	map[string]string				"wishlist or product not found"
+//	@Failure		500		{object}	map[string]string				"internal server error"
+//	@Router			/wishlists/{id}/items [post]
+func (h *Handler) handleAddWishlistItem(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	var payload types.AddWishlistItemPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(payload.ProductID)
+	if err != nil || !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusNotFound, fmt.Errorf("product not found"))
+		return
+	}
+
+	if err := h.store.AddWishlistItem(wishlist.ID, product.ID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "product added to wishlist"})
+}
+
+// handleRemoveWishlistItem removes a product from a wishlist.
+//	@Summary		Remove a product from a wishlist
+//	@Description	Remove a product from a wishlist of the authenticated user
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id			path		int					true	"Wishlist ID"
+//	@Param			productId	path		int					true	"Product ID"
+//	@Success		200			{object}	map[string]string	"message"
+//	@Failure		400			{object}	map[string]string	"invalid wishlist or product ID"
+//	@Failure		401			{object}	map[string]string	"unauthorized"
+//	@Failure		404			{object}	map[string]string	"wishlist not found or product not in it"
+//	@Failure		500			{object}	map[string]string	"internal server error"
+//	@Router			/wishlists/{id}/items/{productId} [delete]
+func (h *Handler) handleRemoveWishlistItem(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	if err := h.store.RemoveWishlistItem(wishlist.ID, productID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.WriteJSON(c.Writer, http.StatusOK, map[string]string{"message": "product removed from wishlist"})
+}
+
+// handleMoveToCart takes a product off a wishlist so it can be bought.
+//	@Summary		Move a wishlist item to the cart
+//	@Description	Remove a product from a wishlist and return the cart item to add to the cart that is checked out through POST /orders. Products that can't be bought right now stay on the wishlist.
+//	@Tags			wishlists
+//	@Produce		json
+//	@Security		apiKey
+//	@Param			id			path		int						true	"Wishlist ID"
+//	@Param			productId	path		int						true	"Product ID"
+//	@Success		200			{object}	types.CartCheckoutItem	"cart item"
+//	@Failure		400			{object}	map[string]string		"invalid wishlist or product ID"
+//	@Failure		401			{object}	map[string]string		"unauthorized"
+//	@Failure		404			{object}	map[string]string		"wishlist not found or product not in it"
+//	@Failure		409			{object}	map[string]string		"product unavailable or out of stock"
+//	@Failure		500			{object}	map[string]string		"internal server error"
+//	@Router			/wishlists/{id}/items/{productId}/move-to-cart [post]
+func (h *Handler) handleMoveToCart(c *gin.Context) {
+	wishlist, ok := h.getOwnWishlist(c)
+	if !ok {
+		return
+	}
+
+	productID, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid product ID"))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil || !product.IsAvailable() {
+		utils.WriteError(c.Writer, http.StatusConflict, fmt.Errorf("product %d is no longer available", productID))
+		return
+	}
+	if !product.IsDigital && product.Quantity < 1 {
+		utils.WriteError(c.Writer, http.StatusConflict, fmt.Errorf("product %d is out of stock", productID))
+		return
+	}
+
+	if err := h.store.RemoveWishlistItem(wishlist.ID, productID); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	utils.Log.WithFields(logrus.Fields{
+		"wishlistID": wishlist.ID,
+		"productID":  productID,
+	}).Info("Wishlist item moved to cart")
+
+	utils.WriteJSON(c.Writer, http.StatusOK, types.CartCheckoutItem{ProductID: productID, Quantity: 1})
+}
+
+// getOwnWishlist loads the wishlist in the URL. Wishlists of other users are reported as
+// not found. It writes the error response and returns false when the wishlist can't be used.
+func (h *Handler) getOwnWishlist(c *gin.Context) (*types.Wishlist, bool) {
+	userID, exists := c.Get(string(middleware.UserKey))
+	if !exists {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid user ID"))
+		return nil, false
+	}
+
+	wishlistID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.WriteError(c.Writer, http.StatusBadRequest, fmt.Errorf("invalid wishlist ID"))
+		return nil, false
+	}
+
+	wishlist, err := h.store.GetWishlistByID(wishlistID)
+	if err == nil && wishlist.UserID != userID.(int) {
+		err = ErrWishlistNotFound
+	}
+	if err != nil {
+		writeStoreError(c, err)
+		return nil, false
+	}
+
+	return wishlist, true
+}
+
+// loadProducts fills in the products of wishlist items that can still be bought
+func (h *Handler) loadProducts(items []types.WishlistItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+	}
+
+	products, err := h.productStore.GetProductsByIDs(ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]types.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	for i := range items {
+		if p, ok := byID[items[i].ProductID]; ok {
+			items[i].Product = &p
+		}
+	}
+
+	return nil
+}
+
+// writeStoreError maps wishlist store errors to HTTP status codes.
+func writeStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrWishlistNotFound), errors.Is(err, ErrItemNotFound):
+		utils.WriteError(c.Writer, http.StatusNotFound, err)
+	case errors.Is(err, ErrWishlistExists):
+		utils.WriteError(c.Writer, http.StatusConflict, err)
+	default:
+		utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// Job is a task that runs periodically in the background.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Run does one pass of the job and returns how many items it processed.
+	Run func() (int, error)
+}
+
+// Start runs every job in its own goroutine, once right away and then on its interval,
+// until ctx is cancelled. Errors are logged and the job keeps running.
+func Start(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		go run(ctx, job)
+	}
+}
+
+func run(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := job.Run()
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"job":   job.Name,
+				"error": err,
+			}).Error("Background job failed")
+		} else if n > 0 {
+			utils.Log.WithFields(logrus.Fields{
+				"job":       job.Name,
+				"processed": n,
+			}).Info("Background job completed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
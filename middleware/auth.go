@@ -1,13 +1,16 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/youngprinnce/go-ecom/config"
+	"github.com/youngprinnce/go-ecom/controller/auth"
+	"github.com/youngprinnce/go-ecom/types"
 	"github.com/youngprinnce/go-ecom/utils"
 )
 
@@ -15,13 +18,52 @@ import (
 type contextKey string
 
 const (
-	UserKey contextKey = "userID"
-	RoleKey contextKey = "role"
+	UserKey    contextKey = "userID"
+	RoleKey    contextKey = "role"
+	SessionKey contextKey = "sessionID"
+	MFAKey     contextKey = "mfa"
+	APIKeyKey  contextKey = "apiKey" // the *types.APIKey requests of integrations are made with
 )
 
+// sessionStore is asked whether the session of a token has been revoked
+var sessionStore types.SessionStore
+
+// SetSessionStore makes JWTAuth reject tokens whose session has been revoked in store.
+func SetSessionStore(store types.SessionStore) {
+	sessionStore = store
+}
+
+// permissionStore is asked which permissions the role of a user has
+var permissionStore types.RoleStore
+
+// SetPermissionStore makes RequirePermission look up the permissions of roles in store.
+func SetPermissionStore(store types.RoleStore) {
+	permissionStore = store
+}
+
+// apiKeyStore looks up the API keys integrations authenticate with
+var apiKeyStore types.APIKeyStore
+
+// SetAPIKeyStore makes JWTAuth accept the API keys in store.
+func SetAPIKeyStore(store types.APIKeyStore) {
+	apiKeyStore = store
+}
+
 // JWTAuth middleware validates the JWT token and sets the userID and role in the request context.
+// Requests with an API key instead, in the X-API-Key header or in the Authorization header, bare
+// or as a Bearer token, get the key in the context and no user, so they can only use routes that
+// require a permission.
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		apiKey := utils.GetAPIKeyFromRequest(c.Request, func(key string) bool {
+			_, ok := auth.APIKeyPrefixOf(key)
+			return ok
+		})
+		if apiKey != "" {
+			apiKeyAuth(c, apiKey)
+			return
+		}
+
 		tokenString := utils.GetTokenFromRequest(c.Request)
 		if tokenString == "" {
 			utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("missing token"))
@@ -29,57 +71,131 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		// Parse the JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.Envs.JWT_SECRET), nil
-		})
-		if err != nil || !token.Valid {
+		// Parse the JWT token, checking its signature, algorithm, issuer, audience and expiry
+		claims, err := auth.ParseJWT(tokenString)
+		if err != nil {
 			utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid token"))
 			c.Abort()
 			return
 		}
 
-		// Extract claims from the token
-		claims := token.Claims.(jwt.MapClaims)
-
-		// Extract userID and role from claims
-		str, ok := claims["userID"].(float64)
-		if !ok {
-			log.Printf("failed to extract userID from token claims")
+		// Extract userID from the subject
+		userID, err := claims.UserID()
+		if err != nil {
+			log.Printf("failed to convert userID to int: %v", err)
 			permissionDenied(c.Writer)
 			c.Abort()
 			return
 		}
 
-		role, ok := claims["role"].(string)
-		if !ok {
+		role := claims.Role
+		if role == "" {
 			log.Printf("failed to extract role from token claims")
 			permissionDenied(c.Writer)
 			c.Abort()
 			return
 		}
 
-		// Convert userID to int
-		userID := int(str)
-		if err != nil {
-			log.Printf("failed to convert userID to int: %v", err)
-			permissionDenied(c.Writer)
+		// Tokens belong to a session, which is revoked on logout or when its refresh token is reused
+		sessionID := claims.SessionID
+		if sessionID == "" {
+			utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid token"))
 			c.Abort()
 			return
 		}
+		if sessionStore != nil {
+			revoked, err := sessionStore.IsSessionRevoked(sessionID)
+			if err != nil {
+				utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+				c.Abort()
+				return
+			}
+			if revoked {
+				utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("token has been revoked"))
+				c.Abort()
+				return
+			}
+
+			// Tokens of disabled users are refused even if their session is still active
+			disabled, err := sessionStore.IsUserDisabled(userID)
+			if err != nil {
+				utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+				c.Abort()
+				return
+			}
+			if disabled {
+				utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("this account has been disabled"))
+				c.Abort()
+				return
+			}
+		}
 
-		// Add userID and role to the request context
+		// Add userID, role and session to the request context
 		c.Set(string(UserKey), userID)
 		c.Set(string(RoleKey), role)
+		c.Set(string(SessionKey), sessionID)
+		c.Set(string(MFAKey), claims.HasAuthMethod(auth.AuthMethodOTP))
 
 		// Call the next handler
 		c.Next()
 	}
 }
 
-// AdminOnly middleware ensures that only users with the "admin" role can access the route.
-func AdminOnly() gin.HandlerFunc {
+// apiKeyAuth authenticates a request with an API key.
+func apiKeyAuth(c *gin.Context, key string) {
+	prefix, ok := auth.APIKeyPrefixOf(key)
+	if !ok || apiKeyStore == nil {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid API key"))
+		c.Abort()
+		return
+	}
+
+	k, err := apiKeyStore.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		log.Printf("failed to get API key %s: %v", prefix, err)
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid API key"))
+		c.Abort()
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(auth.HashToken(key))) != 1 {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("invalid API key"))
+		c.Abort()
+		return
+	}
+	if !k.IsUsable(time.Now()) {
+		utils.WriteError(c.Writer, http.StatusUnauthorized, fmt.Errorf("API key has expired or been revoked"))
+		c.Abort()
+		return
+	}
+
+	// Failing to record the use doesn't stop the request
+	if err := apiKeyStore.TouchAPIKey(k.ID); err != nil {
+		log.Printf("failed to record use of API key %d: %v", k.ID, err)
+	}
+
+	c.Set(string(APIKeyKey), k)
+
+	// Call the next handler
+	c.Next()
+}
+
+// RequirePermission middleware ensures that only users whose role has been granted the
+// permission can access the route. Permissions are looked up on every request, so changes
+// to a role apply right away.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API keys have the permissions they were scoped to
+		if key, ok := c.Get(string(APIKeyKey)); ok {
+			if !key.(*types.APIKey).HasScope(permission) {
+				log.Printf("API key %d does not have scope %q", key.(*types.APIKey).ID, permission)
+				permissionDenied(c.Writer)
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		// Retrieve role from the request context
 		role, exists := c.Get(string(RoleKey))
 		if !exists {
@@ -89,14 +205,31 @@ func AdminOnly() gin.HandlerFunc {
 			return
 		}
 
-		// Check if the user is an admin
-		if role != "admin" {
-			log.Printf("user does not have admin role")
+		// Without a permission store only admins, who hold every permission, are let through
+		allowed := role == types.RoleAdmin
+		if permissionStore != nil {
+			var err error
+			allowed, err = permissionStore.RoleHasPermission(role.(string), permission)
+			if err != nil {
+				utils.WriteError(c.Writer, http.StatusInternalServerError, err)
+				c.Abort()
+				return
+			}
+		}
+		if !allowed {
+			log.Printf("role %q does not have permission %q", role, permission)
 			permissionDenied(c.Writer)
 			c.Abort()
 			return
 		}
 
+		// Staff may have to log in with a second factor
+		if config.Envs.REQUIRE_2FA_FOR_ADMINS && !c.GetBool(string(MFAKey)) {
+			utils.WriteError(c.Writer, http.StatusForbidden, fmt.Errorf("staff must use two-factor authentication, enable it at /users/2fa/setup and log in again"))
+			c.Abort()
+			return
+		}
+
 		// Call the next handler
 		c.Next()
 	}
@@ -0,0 +1,30 @@
+package notification
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/youngprinnce/go-ecom/utils"
+)
+
+// Message is a notification for a customer.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers notifications.
+type Sender interface {
+	Send(Message) error
+}
+
+// ConsoleSender writes notifications to the log instead of delivering them.
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(m Message) error {
+	utils.Log.WithFields(logrus.Fields{
+		"to":      m.To,
+		"subject": m.Subject,
+		"body":    m.Body,
+	}).Info("Notification sent")
+	return nil
+}
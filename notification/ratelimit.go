@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by RateLimitedSender when a recipient has already been sent
+// as many notifications as allowed.
+var ErrRateLimited = errors.New("too many notifications sent to recipient")
+
+// RateLimitedSender passes messages on to another sender, at most limit per recipient within
+// window. Limits are kept in memory, so they are per server and reset on restart.
+type RateLimitedSender struct {
+	sender Sender
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	sent     map[string][]time.Time // when each recipient was sent a message, oldest first
+	prunedAt time.Time
+}
+
+func NewRateLimitedSender(sender Sender, limit int, window time.Duration) *RateLimitedSender {
+	return &RateLimitedSender{
+		sender:   sender,
+		limit:    limit,
+		window:   window,
+		sent:     make(map[string][]time.Time),
+		prunedAt: time.Now(),
+	}
+}
+
+func (s *RateLimitedSender) Send(m Message) error {
+	if !s.allow(m.To, time.Now()) {
+		return ErrRateLimited
+	}
+	return s.sender.Send(m)
+}
+
+// allow records a message to recipient at now, unless the recipient is over the limit
+func (s *RateLimitedSender) allow(recipient string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget messages that have left the window
+	cutoff := now.Add(-s.window)
+	times := s.sent[recipient]
+	for len(times) > 0 && !times[0].After(cutoff) {
+		times = times[1:]
+	}
+
+	if len(times) >= s.limit {
+		if len(times) == 0 {
+			delete(s.sent, recipient)
+		} else {
+			s.sent[recipient] = times
+		}
+		return false
+	}
+	s.sent[recipient] = append(times, now)
+
+	s.prune(now)
+	return true
+}
+
+// prune forgets the recipients whose messages have all left the window, so the map doesn't
+// keep every address ever sent to. It runs at most once per window. The caller holds the lock.
+func (s *RateLimitedSender) prune(now time.Time) {
+	if now.Sub(s.prunedAt) < s.window {
+		return
+	}
+	s.prunedAt = now
+
+	cutoff := now.Add(-s.window)
+	for recipient, times := range s.sent {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(s.sent, recipient)
+		}
+	}
+}
@@ -1,17 +1,22 @@
 package types
 
 import (
+	"errors"
 	"time"
 )
 
 type User struct {
-	ID        int       `json:"id"`
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	Role      string    `json:"role"` // Added role field
-	CreatedAt time.Time `json:"createdAt"`
+	ID              int        `json:"id"`
+	FirstName       string     `json:"firstName"`
+	LastName        string     `json:"lastName"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Role            string     `json:"role"`            // Added role field
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"` // nil until the user follows the link in the verification email
+	TOTPSecret      string     `json:"-"`               // set from 2FA setup on, even before it is enabled
+	TOTPEnabledAt   *time.Time `json:"totpEnabledAt"`   // nil unless two-factor authentication is on
+	DisabledAt      *time.Time `json:"disabledAt"`      // disabled users can't log in or use their tokens
+	CreatedAt       time.Time  `json:"createdAt"`
 }
 
 type RegisterUserPayload struct {
@@ -19,13 +24,135 @@ type RegisterUserPayload struct {
 	LastName  string `json:"lastName" validate:"required"`
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password" validate:"required,min=8"`
-	Role      string `json:"role" validate:"oneof=admin user"`
 }
 
 type UserStore interface {
+	SessionStore
+	TwoFactorStore
 	GetUserByEmail(email string) (*User, error)
 	GetUserByID(id int) (*User, error)
 	CreateUser(User) error
+	SearchUsers(UserSearchQuery) (*UserPage, error)
+	SetUserDisabled(userID int, disabled bool) error
+	SetUserRole(userID int, role string) error
+	ForcePasswordReset(userID int) error
+	UpdateProfile(userID int, firstName, lastName string) error
+	ChangeEmail(userID int, email string) error
+	ChangePassword(userID int, hashedPassword string, keepSessionID string) error
+	VerifyEmail(userID int) error
+	CreateInvitation(UserInvitation) (int, error)
+	GetInvitationByID(id int) (*UserInvitation, error)
+	GetPendingInvitations() ([]UserInvitation, error)
+	RevokeInvitation(id int) error
+	AcceptInvitation(invitationID int, user User) (int, error)
+	CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash string, hashedPassword string) (int, error)
+}
+
+// LoginThrottle holds the recent failed login attempts for an account or an IP address.
+type LoginThrottle struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	BlockedUntil  *time.Time // logins are refused until then
+}
+
+// LoginAttemptStore records failed login attempts, by account and by IP address. Attempts are
+// counted as failures before the credentials are checked and released when they were right, so
+// concurrent attempts can't get past a limit together.
+type LoginAttemptStore interface {
+	ReserveLoginAttempt(key string, since time.Time, backoff func(failures int) time.Duration) (int, *time.Time, error)
+	ReleaseLoginAttempt(key string) error
+	ClearLoginFailures(key string) error
+}
+
+// TwoFactorStore keeps the TOTP secrets and recovery codes of users. Recovery codes are
+// stored hashed.
+type TwoFactorStore interface {
+	SetTOTPSecret(userID int, secret string) error
+	EnableTOTP(userID int, step int64, recoveryCodeHashes []string, keepSessionID string) error
+	DisableTOTP(userID int) error
+	UseTOTPStep(userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required"` // a code from the authenticator app, or a recovery code
+}
+
+type DisableTwoFactorPayload struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type LoginTwoFactorPayload struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// RefreshToken can be exchanged once for a new access token and a new refresh token. The
+// tokens handed out from one login share a family, which is the session access tokens refer to.
+// Only a hash of the token is stored.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	// AuthMethods lists how the user authenticated when the session started, for the amr claim
+	AuthMethods []string
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// SessionStore keeps the sessions of users and tells whether their tokens may still be used.
+type SessionStore interface {
+	CreateRefreshToken(RefreshToken) error
+	RotateRefreshToken(tokenHash string, next RefreshToken) (*RefreshToken, error)
+	RevokeSession(familyID string) error
+	RevokeUserSessions(userID int) error
+	IsSessionRevoked(familyID string) (bool, error)
+	IsUserDisabled(userID int) (bool, error)
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// UserInvitation lets a staff member create an account with the invited role. It can be
+// accepted once, until it expires or is revoked.
+type UserInvitation struct {
+	ID         int        `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	InvitedBy  *int       `json:"invitedBy,omitempty"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type InviteUserPayload struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"omitempty,max=64"` // defaults to admin
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+type AcceptInvitationPayload struct {
+	Token     string `json:"token" validate:"required"`
+	FirstName string `json:"firstName" validate:"required"`
+	LastName  string `json:"lastName" validate:"required"`
+	Password  string `json:"password" validate:"required,min=8"`
 }
 
 type LoginUserPayload struct {
@@ -33,33 +160,357 @@ type LoginUserPayload struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// UserSearchQuery filters and paginates the user list of admins.
+type UserSearchQuery struct {
+	Query   string // matches names and emails
+	Role    string
+	Status  string // "active" or "disabled", or empty for both
+	Page    int    // 1-based
+	PerPage int
+}
+
+type UserPage struct {
+	Total   int    `json:"total"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"perPage"`
+	Users   []User `json:"users"`
+}
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,max=64"`
+}
+
+// AuditLog records an action an admin took.
+type AuditLog struct {
+	ID         int            `json:"id"`
+	ActorID    *int           `json:"actorID"` // nil once the admin has been deleted
+	Action     string         `json:"action"`
+	TargetType string         `json:"targetType"`
+	TargetID   *int           `json:"targetID"`
+	Details    map[string]any `json:"details,omitempty"`
+	IP         string         `json:"ip"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+type AuditLogQuery struct {
+	ActorID    int // 0 for any admin
+	TargetType string
+	TargetID   int // 0 for any target
+	Action     string
+	Limit      int
+}
+
+type AuditStore interface {
+	CreateAuditLog(AuditLog) error
+	GetAuditLogs(AuditLogQuery) ([]AuditLog, error)
+}
+
+type UpdateProfilePayload struct {
+	FirstName string `json:"firstName" validate:"required"`
+	LastName  string `json:"lastName" validate:"required"`
+}
+
+type ChangeEmailPayload struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"` // the current password
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+}
+
+// Roles every store has. Admins hold every permission, users (customers) none.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permissions staff roles can be granted
+const (
+	PermissionProductsRead    = "products:read"
+	PermissionProductsWrite   = "products:write"
+	PermissionAttributesWrite = "attributes:write"
+	PermissionOrdersRead      = "orders:read"
+	PermissionOrdersWrite     = "orders:write"
+	PermissionReviewsModerate = "reviews:moderate"
+	PermissionUsersRead       = "users:read"
+	PermissionUsersWrite      = "users:write"
+	PermissionRolesManage     = "roles:manage"
+	PermissionAuditRead       = "audit:read"
+	PermissionAPIKeysManage   = "api_keys:manage"
+)
+
+// Role is a named set of permissions users are assigned. Built-in roles can't be changed
+// or deleted.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	BuiltIn     bool      `json:"builtIn"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleStore interface {
+	GetRoles() ([]Role, error)
+	GetRole(name string) (*Role, error)
+	CreateRole(Role) error
+	UpdateRole(Role) error
+	DeleteRole(name string) error
+	GetPermissions() ([]Permission, error)
+	RoleHasPermission(role, permission string) (bool, error)
+}
+
+type CreateRolePayload struct {
+	Name        string   `json:"name" validate:"required,max=64"` // lowercase letters, digits and underscores
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type UpdateRolePayload struct {
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"required,dive,required"`
+}
+
+// APIKey lets an integration call staff routes without logging in. Its scopes are the
+// permissions it has. Only the hash of the key is stored; the prefix identifies it.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *int       `json:"createdBy"` // nil once the user has been deleted
+	ExpiresAt  *time.Time `json:"expiresAt"` // nil for keys that don't expire
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// HasScope reports whether the key has been granted the permission.
+func (k *APIKey) HasScope(permission string) bool {
+	for _, scope := range k.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUsable reports whether the key can still be used at the given time.
+func (k *APIKey) IsUsable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+type APIKeyStore interface {
+	CreateAPIKey(APIKey) (int, error)
+	GetAPIKeys() ([]APIKey, error)
+	GetAPIKeyByID(id int) (*APIKey, error)
+	GetAPIKeyByPrefix(prefix string) (*APIKey, error)
+	RevokeAPIKey(id int) error
+	TouchAPIKey(id int) error
+}
+
+type CreateAPIKeyPayload struct {
+	Name      string     `json:"name" validate:"required,max=255"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expiresAt"` // leave out for a key that doesn't expire
+}
+
 type Product struct {
 	ID          int     `json:"id"`
+	SKU         string  `json:"sku"`
+	Slug        string  `json:"slug"`
+	Kind        string  `json:"kind"`      // "simple", or "bundle" for products sold as a set of other products
+	IsDigital   bool    `json:"isDigital"` // digital products are downloaded after payment and have no stock
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Image       string  `json:"image"`
 	Price       float64 `json:"price"`
+	// CompareAtPrice is the regular price while a sale is running, shown struck through in the catalog
+	CompareAtPrice *float64 `json:"compareAtPrice,omitempty"`
 	// note that this isn't the best way to handle quantity
 	// because it's not atomic (in ACID), but it's good enough for this example
-	Quantity  int       `json:"quantity"`
-	CreatedAt time.Time `json:"createdAt"`
+	Quantity        int        `json:"quantity"`
+	AverageRating   float64    `json:"averageRating"` // average of approved review ratings
+	ReviewCount     int        `json:"reviewCount"`   // number of approved reviews
+	MetaTitle       string     `json:"metaTitle"`
+	MetaDescription string     `json:"metaDescription"`
+	Status          string     `json:"status"`              // one of the ProductStatus constants
+	PublishAt       *time.Time `json:"publishAt,omitempty"` // when the product went, or is scheduled to go, live
+	// Components is set on bundles when they are retrieved on their own. The quantity of a
+	// bundle is computed from the stock of its components.
+	Components []BundleComponent `json:"components,omitempty"`
+	Version    int               `json:"version"`             // bumped on every change, guards updates against lost writes
+	DeletedAt  *time.Time        `json:"deletedAt,omitempty"` // set when the product is archived
+	CreatedAt  time.Time         `json:"createdAt"`
 }
 
+// Publication statuses of a product. Draft and scheduled products are only visible to admins,
+// unlisted products can be reached and bought through a direct link but are left out of listings.
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusPublished = "published"
+	ProductStatusScheduled = "scheduled"
+	ProductStatusUnlisted  = "unlisted"
+)
+
+// Product kinds
+const (
+	ProductKindSimple = "simple"
+	ProductKindBundle = "bundle"
+)
+
+// IsAvailable reports whether customers can view and buy the product
+func (p *Product) IsAvailable() bool {
+	return p.DeletedAt == nil && (p.Status == ProductStatusPublished || p.Status == ProductStatusUnlisted)
+}
+
+// ErrProductVersionConflict is returned by ProductStore.UpdateProduct when the product
+// was changed by someone else since the given version was read.
+var ErrProductVersionConflict = errors.New("product has been modified since it was read")
+
 type ProductStore interface {
 	GetProductsByIDs(ids []int) ([]Product, error)
 	GetProducts() ([]*Product, error)
-	CreateProduct(CreateProductPayload) error
+	GetPublishedProducts() ([]*Product, error)
+	GetArchivedProducts() ([]*Product, error)
+	GetProductsBySKUs(skus []string) ([]Product, error)
+	CreateProduct(CreateProductPayload) (int, error)
+	UpsertProducts(rows []ProductImportRow) (created int, updated int, err error)
+	GetPriceHistory(productID int) ([]PriceHistoryEntry, error)
+	GetPriceSchedules(productID int) ([]PriceSchedule, error)
+	CreatePriceSchedule(PriceSchedule) (int, error)
+	CancelPriceSchedule(productID, scheduleID int) error
+	ApplyDuePriceSchedules() (int, error)
 	UpdateProduct(Product) error
-	DeleteProduct(productID int) error
+	PatchProduct(productID, version int, changes map[string]interface{}) error
+	SetProductStatus(productID int, status string, publishAt *time.Time) error
+	GetBundleComponents(bundleID int) ([]BundleComponent, error)
+	GetBundlesContaining(componentID int) ([]Product, error)
+	SetBundleComponents(bundleID int, components []BundleComponent) error
+	ClearBundleComponents(bundleID int) error
+	PublishDueProducts() (int, error)
+	ArchiveProduct(productID int) error
+	RestoreProduct(productID int) error
+	PurgeProduct(productID int) error
 	GetProductByID(id int) (*Product, error)
+	GetProductBySlug(slug string) (*Product, error)
 }
 
 type CreateProductPayload struct {
-	Name        string  `json:"name" validate:"required"`
-	Description string  `json:"description"`
-	Image       string  `json:"image"`
-	Price       float64 `json:"price" validate:"required"`
-	Quantity    int     `json:"quantity" validate:"required"`
+	SKU  string `json:"sku" validate:"omitempty,max=64"`
+	Name string `json:"name" validate:"required"`
+	// IsDigital products ignore Quantity
+	IsDigital bool `json:"isDigital"`
+	// Slug is generated from the name when left empty; on updates an empty slug keeps the current one
+	Slug            string  `json:"slug" validate:"omitempty,max=255,slug"`
+	Description     string  `json:"description"`
+	Image           string  `json:"image"`
+	Price           float64 `json:"price" validate:"required"`
+	Quantity        int     `json:"quantity" validate:"required_unless=IsDigital true"`
+	MetaTitle       string  `json:"metaTitle" validate:"max=255"`
+	MetaDescription string  `json:"metaDescription" validate:"max=500"`
+}
+
+// ProductPatchDocument holds the product fields that can be changed with PATCH.
+// A patch is applied to the current values and the result must validate as a whole.
+type ProductPatchDocument struct {
+	SKU             string  `json:"sku" validate:"omitempty,max=64"`
+	Slug            string  `json:"slug" validate:"required,max=255,slug"`
+	Name            string  `json:"name" validate:"required,max=255"`
+	Description     string  `json:"description"`
+	Image           string  `json:"image" validate:"max=255"`
+	Price           float64 `json:"price" validate:"required,gt=0"`
+	Quantity        int     `json:"quantity" validate:"min=0"`
+	IsDigital       bool    `json:"isDigital"`
+	MetaTitle       string  `json:"metaTitle" validate:"max=255"`
+	MetaDescription string  `json:"metaDescription" validate:"max=500"`
+}
+
+// UpdateProductStatusPayload changes the publication status of a product.
+// PublishAt is required for, and only used by, the scheduled status.
+type UpdateProductStatusPayload struct {
+	Status    string     `json:"status" validate:"required,oneof=draft published scheduled unlisted"`
+	PublishAt *time.Time `json:"publishAt" validate:"required_if=Status scheduled"`
+}
+
+// BundleComponent is a product included in a bundle, Quantity times per bundle
+type BundleComponent struct {
+	ProductID int    `json:"productID" validate:"required"`
+	Name      string `json:"name,omitempty"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+}
+
+type SetBundleComponentsPayload struct {
+	Components []BundleComponent `json:"components" validate:"required,min=1,dive"`
+}
+
+// PriceHistoryEntry records a change to a product's price. Source says what made the
+// change: "manual", "import", "schedule", "sale_start", "sale_end" or "sale_cancelled".
+type PriceHistoryEntry struct {
+	ID             int       `json:"id"`
+	ProductID      int       `json:"productID"`
+	Price          float64   `json:"price"`
+	CompareAtPrice *float64  `json:"compareAtPrice,omitempty"`
+	Source         string    `json:"source"`
+	ScheduleID     *int      `json:"scheduleID,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// PriceSchedule is a future price change. A "price" schedule replaces the regular price
+// at StartsAt; a "sale" schedule lowers the price between StartsAt and EndsAt and shows
+// the regular price as the compare-at price meanwhile.
+type PriceSchedule struct {
+	ID        int        `json:"id"`
+	ProductID int        `json:"productID"`
+	Type      string     `json:"type"`
+	Price     float64    `json:"price"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type CreatePriceSchedulePayload struct {
+	Type     string     `json:"type" validate:"required,oneof=price sale"`
+	Price    float64    `json:"price" validate:"required,gt=0"`
+	StartsAt time.Time  `json:"startsAt" validate:"required"`
+	EndsAt   *time.Time `json:"endsAt"`
+}
+
+// ProductImportRow is a single product read from a CSV or JSON import file.
+// Rows are matched to existing products by SKU. Without a status, new products are
+// created as drafts and existing products keep theirs.
+type ProductImportRow struct {
+	Row         int        `json:"-"` // 1-based position of the record in the file, not counting the CSV header
+	SKU         string     `json:"sku" validate:"required,max=64"`
+	Name        string     `json:"name" validate:"required,max=255"`
+	Description string     `json:"description"`
+	Image       string     `json:"image" validate:"max=255"`
+	Price       float64    `json:"price" validate:"required,gt=0"`
+	Quantity    int        `json:"quantity" validate:"min=0"`
+	Status      string     `json:"status,omitempty" validate:"omitempty,oneof=draft published scheduled unlisted"`
+	PublishAt   *time.Time `json:"publishAt,omitempty" validate:"required_if=Status scheduled"` // only used by the scheduled status
+}
+
+type ProductImportRowError struct {
+	Row   int    `json:"row"` // 1-based position of the record in the file, not counting the CSV header
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+type ProductImportReport struct {
+	DryRun  bool                    `json:"dryRun"`
+	Total   int                     `json:"total"`
+	Created int                     `json:"created"`
+	Updated int                     `json:"updated"`
+	Errors  []ProductImportRowError `json:"errors"`
 }
 
 type OrderStore interface {
@@ -68,7 +519,7 @@ type OrderStore interface {
 	GetOrdersByUserID(userID int) ([]Order, error)
 	GetOrderByID(orderID int) (*Order, error)
 	UpdateOrderStatus(orderID int, status string) error
-	CancelOrder(orderID int, userID int) error
+	CancelOrder(orderID int, userID int) ([]int, error)
 	GetOrderItemsByOrderID(orderID int) ([]OrderItem, error)
 }
 
@@ -82,16 +533,18 @@ type Order struct {
 }
 
 type OrderItem struct {
-	ID        int       `json:"id"`
-	OrderID   int       `json:"orderID"`
-	ProductID int       `json:"productID"`
-	Quantity  int       `json:"quantity"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        int     `json:"id"`
+	OrderID   int     `json:"orderID"`
+	ProductID int     `json:"productID"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	// Components records what a bundle consisted of when it was ordered, per bundle
+	Components []BundleComponent `json:"components,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
 }
 
 type UpdateOrderStatusPayload struct {
-	Status string `json:"status" validate:"required,oneof=pending successful cancelled"`
+	Status string `json:"status" validate:"required,oneof=pending successful delivered cancelled"`
 }
 
 type CartCheckoutPayload struct {
@@ -102,3 +555,276 @@ type CartCheckoutItem struct {
 	ProductID int `json:"productID"`
 	Quantity  int `json:"quantity"`
 }
+
+// ProductFile is a file customers get to download after buying a digital product
+type ProductFile struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"productID"`
+	Name      string    `json:"name"` // file name offered to the customer
+	Path      string    `json:"-"`    // location of the file, relative to the digital files directory
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DownloadableFile is a product file that was bought with an order item
+type DownloadableFile struct {
+	File          ProductFile
+	OrderItemID   int
+	OrderID       int
+	UserID        int
+	OrderStatus   string
+	DownloadCount int
+}
+
+// DownloadLink is a signed, time-limited URL to a purchased file
+type DownloadLink struct {
+	OrderItemID        int       `json:"orderItemID"`
+	ProductID          int       `json:"productID"`
+	FileID             int       `json:"fileID"`
+	Name               string    `json:"name"`
+	Size               int64     `json:"size"`
+	URL                string    `json:"url"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+	DownloadsRemaining int       `json:"downloadsRemaining"`
+}
+
+type DownloadStore interface {
+	CreateProductFile(ProductFile) (int, error)
+	GetProductFiles(productID int) ([]ProductFile, error)
+	GetProductFile(productID, fileID int) (*ProductFile, error)
+	DeleteProductFile(productID, fileID int) error
+	GetDownloadableFiles(orderID int) ([]DownloadableFile, error)
+	GetDownloadableFile(orderItemID, fileID int) (*DownloadableFile, error)
+	RecordDownload(orderItemID, maxDownloads int) error
+}
+
+// Attribute is an admin-defined product property, such as brand or screen size.
+// Type is "text", "number" or "boolean" and decides how values are validated and filtered.
+type Attribute struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"` // used as the key when assigning values and filtering
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Unit      string    `json:"unit,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateAttributePayload struct {
+	Code string `json:"code" validate:"required,max=64,slug"`
+	Name string `json:"name" validate:"required,max=255"`
+	Type string `json:"type" validate:"required,oneof=text number boolean"`
+	Unit string `json:"unit" validate:"max=32"`
+}
+
+// UpdateAttributePayload changes how an attribute is shown. Its code and type can't
+// change because product values and filters depend on them.
+type UpdateAttributePayload struct {
+	Name string `json:"name" validate:"required,max=255"`
+	Unit string `json:"unit" validate:"max=32"`
+}
+
+// ProductAttributeValue is the value of an attribute for a product. Value is stored as text
+// and decoded to a string, number or boolean according to the attribute type.
+type ProductAttributeValue struct {
+	ProductID   int    `json:"-"`
+	AttributeID int    `json:"attributeID"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Unit        string `json:"unit,omitempty"`
+	Value       string `json:"value"`
+}
+
+// SetProductAttributesPayload assigns attribute values to a product, keyed by attribute code.
+// It replaces all values the product had.
+type SetProductAttributesPayload struct {
+	Attributes map[string]interface{} `json:"attributes" validate:"required"`
+}
+
+type AttributeStore interface {
+	GetAttributes() ([]Attribute, error)
+	GetAttributeByID(id int) (*Attribute, error)
+	CreateAttribute(Attribute) (int, error)
+	UpdateAttribute(Attribute) error
+	DeleteAttribute(id int) error
+	GetProductAttributes(productID int) ([]ProductAttributeValue, error)
+	GetProductsAttributes(productIDs []int) ([]ProductAttributeValue, error)
+	SetProductAttributes(productID int, values []ProductAttributeValue) error
+	SearchProducts(query ProductSearchQuery) ([]int, int, error)
+	CountFacetValues(query ProductSearchQuery) ([]FacetCount, error)
+}
+
+// AttributeFilter is the condition a catalog search puts on one attribute. A product matches
+// when its value is one of Values, compared case-insensitively, or for number attributes when
+// it lies within Min and Max.
+type AttributeFilter struct {
+	AttributeID int
+	Values      []string
+	Min, Max    *float64
+}
+
+type ProductSearchQuery struct {
+	Query   string // matches names, descriptions and SKUs
+	Filters []AttributeFilter
+	Page    int // 1-based
+	PerPage int
+}
+
+// FacetCount is how many products matching a search have a value of an attribute. Values
+// that only differ in case are counted together.
+type FacetCount struct {
+	AttributeID int
+	Value       string
+	Count       int
+}
+
+// FacetValue is an attribute value found in search results and how many results have it
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facet lists the values of an attribute among the products matching all other filters
+type Facet struct {
+	Code   string       `json:"code"`
+	Name   string       `json:"name"`
+	Type   string       `json:"type"`
+	Unit   string       `json:"unit,omitempty"`
+	Values []FacetValue `json:"values"`
+}
+
+type ProductSearchResult struct {
+	Total    int        `json:"total"`
+	Page     int        `json:"page"`
+	PerPage  int        `json:"perPage"`
+	Products []*Product `json:"products"`
+	Facets   []Facet    `json:"facets"`
+}
+
+// Recommendation is a product suggested because it is often bought together with
+// other products. Score is the share of their paid orders that also contained it.
+type Recommendation struct {
+	ProductID int     `json:"productID"`
+	Score     float64 `json:"score"`
+}
+
+type RecommendationStore interface {
+	ComputeRecommendations(minOrders int) (int, error)
+	GetRecommendations(productIDs []int, limit int) ([]Recommendation, error)
+}
+
+type CartRecommendationsPayload struct {
+	ProductIDs []int `json:"productIDs" validate:"required,min=1,max=50"`
+}
+
+type Review struct {
+	ID           int       `json:"id"`
+	ProductID    int       `json:"productID"`
+	UserID       int       `json:"userID"`
+	Rating       int       `json:"rating"`
+	Title        string    `json:"title"`
+	Body         string    `json:"body"`
+	Status       string    `json:"status"`
+	HelpfulCount int       `json:"helpfulCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type ReviewStore interface {
+	HasDeliveredProduct(userID, productID int) (bool, error)
+	CreateReview(Review) (int, error)
+	GetReviewByID(id int) (*Review, error)
+	GetApprovedReviewsByProductID(productID int) ([]Review, error)
+	GetReviewsByStatus(status string) ([]Review, error)
+	UpdateReviewStatus(reviewID int, status string) error
+	AddHelpfulVote(reviewID, userID int) error
+}
+
+type CreateReviewPayload struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title" validate:"required,max=255"`
+	Body   string `json:"body"`
+}
+
+type UpdateReviewStatusPayload struct {
+	Status string `json:"status" validate:"required,oneof=approved hidden"`
+}
+
+type Wishlist struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"userID"`
+	Name   string `json:"name"`
+	// ShareToken is set while the wishlist is shared, anyone with the token can view it
+	ShareToken string         `json:"shareToken,omitempty"`
+	Items      []WishlistItem `json:"items"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+type WishlistItem struct {
+	ProductID int `json:"productID"`
+	// Product is left out when the product can no longer be bought
+	Product   *Product  `json:"product,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SharedWishlist is the read-only view of a wishlist shown to anyone with its share link
+type SharedWishlist struct {
+	Name      string         `json:"name"`
+	Items     []WishlistItem `json:"items"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type WishlistStore interface {
+	GetWishlistsByUserID(userID int) ([]Wishlist, error)
+	GetWishlistByID(id int) (*Wishlist, error)
+	GetWishlistByShareToken(token string) (*Wishlist, error)
+	CreateWishlist(Wishlist) (int, error)
+	RenameWishlist(wishlistID int, name string) error
+	DeleteWishlist(wishlistID int) error
+	SetWishlistShareToken(wishlistID int, token string) error
+	AddWishlistItem(wishlistID, productID int) error
+	RemoveWishlistItem(wishlistID, productID int) error
+	GetUsersWishingFor(productID int) ([]User, error)
+}
+
+type WishlistPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type AddWishlistItemPayload struct {
+	ProductID int `json:"productID" validate:"required"`
+}
+
+// RestockNotifier is told when a product that was out of stock can be bought again.
+type RestockNotifier interface {
+	NotifyRestocked(product Product)
+}
+
+// RestockNotifiers passes a restock on to each of several notifiers in turn
+type RestockNotifiers []RestockNotifier
+
+func (n RestockNotifiers) NotifyRestocked(product Product) {
+	for _, notifier := range n {
+		notifier.NotifyRestocked(product)
+	}
+}
+
+// StockSubscription asks for a notification when a product is back in stock. It is
+// fulfilled once the notification is sent and can be renewed by subscribing again.
+type StockSubscription struct {
+	ProductID  int        `json:"productID"`
+	UserID     int        `json:"userID"`
+	NotifiedAt *time.Time `json:"notifiedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type StockSubscriptionStore interface {
+	GetStockSubscriptionsByUserID(userID int) ([]StockSubscription, error)
+	SubscribeToStock(productID, userID int) error
+	UnsubscribeFromStock(productID, userID int) error
+	GetStockSubscribers(productID int) ([]User, error)
+	MarkStockSubscriptionNotified(productID, userID int) (bool, error)
+}
+
+type StockSubscriptionPayload struct {
+	ProductID int `json:"productID" validate:"required"`
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// assertJSONEqual fails the test unless got and want decode to the same value,
+// so the key order of the marshalled result doesn't matter.
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not valid JSON: %v (%s)", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("expected value is not valid JSON: %v (%s)", err, want)
+	}
+
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// The examples of RFC 7396, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"remove one of several members", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"replace array with string", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"replace string with array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"merge nested object", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"replace array of objects", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"replace array", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"replace object with array", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"replace object with null", `{"a":"foo"}`, `null`, `null`},
+		{"replace object with string", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"keep null members of the document", `{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{"replace array with object", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"create nested objects", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatchInvalidJSON(t *testing.T) {
+	if _, err := MergePatch([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("expected an error for an invalid document")
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Error("expected an error for an invalid merge patch")
+	}
+}
+
+// The examples of RFC 6902, appendix A, plus the errors the handlers rely on.
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "A.8 testing a value: success",
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:    "A.9 testing a value: error",
+			doc:     `{"baz":"qux"}`,
+			patch:   `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: true,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:    "A.12 adding to a nonexistent target",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10}]`,
+			want:  `{"/":9,"~1":10}`,
+		},
+		{
+			name:    "A.15 comparing strings and numbers",
+			doc:     `{"/":9,"~1":10}`,
+			patch:   `[{"op":"test","path":"/~01","value":"10"}]`,
+			wantErr: true,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:  "copying a value",
+			doc:   `{"foo":{"bar":"baz"}}`,
+			patch: `[{"op":"copy","from":"/foo/bar","path":"/qux"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":"baz"}`,
+		},
+		{
+			name:  "replacing the whole document",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"replace","path":"","value":{"baz":"qux"}}]`,
+			want:  `{"baz":"qux"}`,
+		},
+		{
+			name:  "setting a null value",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/foo","value":null}]`,
+			want:  `{"foo":null}`,
+		},
+		{
+			name:  "comparing numbers regardless of their spelling",
+			doc:   `{"foo":1}`,
+			patch: `[{"op":"test","path":"/foo","value":1.0}]`,
+			want:  `{"foo":1}`,
+		},
+		{
+			name:    "unknown operation",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"merge","path":"/foo","value":"baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "replacing a nonexistent member",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"replace","path":"/baz","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "removing a nonexistent member",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":"/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "array index out of range",
+			doc:     `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "array index with a leading zero",
+			doc:     `{"foo":["bar","baz"]}`,
+			patch:   `[{"op":"remove","path":"/foo/01"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "path without a leading slash",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":"foo"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "moving a value into one of its children",
+			doc:     `{"foo":{"bar":"baz"}}`,
+			patch:   `[{"op":"move","from":"/foo","path":"/foo/bar/qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "removing the whole document",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":""}]`,
+			wantErr: true,
+		},
+		{
+			name:    "failing operation discards the earlier ones",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz","value":"qux"},{"op":"test","path":"/foo","value":"baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "patch is not an array",
+			doc:     `{"foo":"bar"}`,
+			patch:   `{"op":"add","path":"/baz","value":"qux"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid document",
+			doc:     `{"foo":`,
+			patch:   `[]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
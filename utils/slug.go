@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// maxSlugLength leaves room for the "-N" suffix added to make slugs unique
+const maxSlugLength = 200
+
+var (
+	slugPattern  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugReplacer = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func init() {
+	// "slug" accepts lowercase letters and digits separated by single dashes
+	Validate.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+}
+
+// Slugify turns a name into a URL-friendly slug, e.g. "Men's T-Shirt (XL)" becomes "men-s-t-shirt-xl".
+func Slugify(name string) string {
+	slug := strings.Trim(slugReplacer.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if slug == "" {
+		return "product"
+	}
+	return slug
+}
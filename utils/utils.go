@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -19,6 +20,21 @@ func WriteError(w http.ResponseWriter, status int, err error) {
 	WriteJSON(w, status, map[string]string{"error": err.Error()})
 }
 
+// GetAPIKeyFromRequest returns the API key a request was made with, or "" if there is none.
+// Keys are sent in the X-API-Key header, or in the Authorization header, bare or as a Bearer
+// token. isAPIKey tells keys apart from the access tokens also sent in the Authorization header.
+func GetAPIKeyFromRequest(r *http.Request, isAPIKey func(string) bool) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		authorization = authorization[len("Bearer "):]
+	}
+	if isAPIKey(authorization) {
+		return authorization
+	}
+
+	return r.Header.Get("X-API-Key")
+}
+
 // It checks the Authorization header for a Bearer token and falls back to the query parameter "token".
 func GetTokenFromRequest(r *http.Request) string {
 	// Check the Authorization header for a Bearer token
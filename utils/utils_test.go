@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetAPIKeyFromRequest(t *testing.T) {
+	const key = "gek_1a2b3c4d_secret"
+	isAPIKey := func(k string) bool { return strings.HasPrefix(k, "gek_") }
+
+	tests := []struct {
+		name          string
+		xAPIKey       string
+		authorization string
+		want          string
+	}{
+		{"X-API-Key header", key, "", key},
+		{"bare Authorization header", "", key, key},
+		{"Bearer Authorization header", "", "Bearer " + key, key},
+		{"lowercase bearer scheme", "", "bearer " + key, key},
+		{"Authorization header takes precedence", "gek_other", "Bearer " + key, key},
+		{"access token in the Authorization header", "", "eyJhbGciOiJIUzI1NiJ9.e30.sig", ""},
+		{"access token as a Bearer token", "", "Bearer eyJhbGciOiJIUzI1NiJ9.e30.sig", ""},
+		{"access token and X-API-Key header", key, "eyJhbGciOiJIUzI1NiJ9.e30.sig", key},
+		{"no credentials", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/products", nil)
+			if tt.xAPIKey != "" {
+				r.Header.Set("X-API-Key", tt.xAPIKey)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			if got := GetAPIKeyFromRequest(r, isAPIKey); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}